@@ -0,0 +1,588 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/bnixon67/go-webserver/webserver"
+	"golang.org/x/net/http/httpguts"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that is read from config files and flags
+// using the time.ParseDuration format, e.g., "5s" or "2m30s".
+type Duration time.Duration
+
+// String returns the duration formatted as with time.Duration.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses s as a duration. It implements flag.Value.
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %s", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+// List is a list of strings that is set from a flag as comma-separated values.
+type List []string
+
+// String returns the list as comma-separated values.
+func (l List) String() string {
+	return strings.Join(l, ",")
+}
+
+// Set replaces the list with the comma-separated values in s.
+// It implements flag.Value.
+func (l *List) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// VirtualHost holds the settings for a host with its own routes and
+// application name. Virtual hosts can only be set in a config file.
+type VirtualHost struct {
+	Host     string `json:"host" yaml:"host" toml:"host"`
+	AppName  string `json:"appname" yaml:"appname" toml:"appname"`
+	Routes   List   `json:"routes" yaml:"routes" toml:"routes"`
+	CertFile string `json:"certfile" yaml:"certfile" toml:"certfile"`
+	KeyFile  string `json:"keyfile" yaml:"keyfile" toml:"keyfile"`
+}
+
+// Config holds the settings for the command. The names used in config files
+// match the command-line flag names.
+type Config struct {
+	Addr                      string        `json:"addr" yaml:"addr" toml:"addr"`
+	Listen                    List          `json:"listen" yaml:"listen" toml:"listen"`
+	HTMLDir                   string        `json:"html" yaml:"html" toml:"html"`
+	LogFile                   string        `json:"logfile" yaml:"logfile" toml:"logfile"`
+	LogLevel                  string        `json:"loglevel" yaml:"loglevel" toml:"loglevel"`
+	LogType                   string        `json:"logtype" yaml:"logtype" toml:"logtype"`
+	LogSource                 bool          `json:"logsource" yaml:"logsource" toml:"logsource"`
+	LogMaxSize                int           `json:"logmaxsize" yaml:"logmaxsize" toml:"logmaxsize"`
+	LogDaily                  bool          `json:"logdaily" yaml:"logdaily" toml:"logdaily"`
+	LogMaxFiles               int           `json:"logmaxfiles" yaml:"logmaxfiles" toml:"logmaxfiles"`
+	LogCompress               bool          `json:"logcompress" yaml:"logcompress" toml:"logcompress"`
+	AccessLog                 string        `json:"accesslog" yaml:"accesslog" toml:"accesslog"`
+	AccessLogFormat           string        `json:"accesslogformat" yaml:"accesslogformat" toml:"accesslogformat"`
+	CertFile                  string        `json:"certfile" yaml:"certfile" toml:"certfile"`
+	KeyFile                   string        `json:"keyfile" yaml:"keyfile" toml:"keyfile"`
+	ClientCA                  string        `json:"clientca" yaml:"clientca" toml:"clientca"`
+	ClientAuth                string        `json:"clientauth" yaml:"clientauth" toml:"clientauth"`
+	Certificates              List          `json:"certificates" yaml:"certificates" toml:"certificates"`
+	CertWatch                 Duration      `json:"certwatch" yaml:"certwatch" toml:"certwatch"`
+	ACMEDomains               List          `json:"acme-domains" yaml:"acme-domains" toml:"acme-domains"`
+	ACMECache                 string        `json:"acme-cache" yaml:"acme-cache" toml:"acme-cache"`
+	ACMEDirectory             string        `json:"acme-directory" yaml:"acme-directory" toml:"acme-directory"`
+	ACMEEmail                 string        `json:"acme-email" yaml:"acme-email" toml:"acme-email"`
+	ACMEHTTPAddr              string        `json:"acme-http-addr" yaml:"acme-http-addr" toml:"acme-http-addr"`
+	ACMEWebroot               string        `json:"acme-webroot" yaml:"acme-webroot" toml:"acme-webroot"`
+	RedirectAddr              string        `json:"redirect-addr" yaml:"redirect-addr" toml:"redirect-addr"`
+	RedirectStatus            int           `json:"redirect-status" yaml:"redirect-status" toml:"redirect-status"`
+	HTTP3Addr                 string        `json:"http3-addr" yaml:"http3-addr" toml:"http3-addr"`
+	HSTSMaxAge                Duration      `json:"hsts-max-age" yaml:"hsts-max-age" toml:"hsts-max-age"`
+	HSTSIncludeSubDomains     bool          `json:"hsts-include-subdomains" yaml:"hsts-include-subdomains" toml:"hsts-include-subdomains"`
+	HSTSPreload               bool          `json:"hsts-preload" yaml:"hsts-preload" toml:"hsts-preload"`
+	ReadTimeout               Duration      `json:"readtimeout" yaml:"readtimeout" toml:"readtimeout"`
+	WriteTimeout              Duration      `json:"writetimeout" yaml:"writetimeout" toml:"writetimeout"`
+	IdleTimeout               Duration      `json:"idletimeout" yaml:"idletimeout" toml:"idletimeout"`
+	ShutdownTimeout           Duration      `json:"shutdowntimeout" yaml:"shutdowntimeout" toml:"shutdowntimeout"`
+	Routes                    List          `json:"routes" yaml:"routes" toml:"routes"`
+	RequestID                 string        `json:"requestid" yaml:"requestid" toml:"requestid"`
+	RequestIDHeader           string        `json:"requestidheader" yaml:"requestidheader" toml:"requestidheader"`
+	TrustRequestID            bool          `json:"trustrequestid" yaml:"trustrequestid" toml:"trustrequestid"`
+	TrustedProxies            List          `json:"trustedproxies" yaml:"trustedproxies" toml:"trustedproxies"`
+	ClientIPHeaders           List          `json:"clientipheaders" yaml:"clientipheaders" toml:"clientipheaders"`
+	ProxyProtocol             bool          `json:"proxyprotocol" yaml:"proxyprotocol" toml:"proxyprotocol"`
+	ProxyProtocolSources      List          `json:"proxyprotocolsources" yaml:"proxyprotocolsources" toml:"proxyprotocolsources"`
+	ProxyHeaderTimeout        Duration      `json:"proxyheadertimeout" yaml:"proxyheadertimeout" toml:"proxyheadertimeout"`
+	H2C                       bool          `json:"h2c" yaml:"h2c" toml:"h2c"`
+	HTTP2MaxConcurrentStreams uint          `json:"http2-max-concurrent-streams" yaml:"http2-max-concurrent-streams" toml:"http2-max-concurrent-streams"`
+	HTTP2MaxFrameSize         uint          `json:"http2-max-frame-size" yaml:"http2-max-frame-size" toml:"http2-max-frame-size"`
+	HTTP2IdleTimeout          Duration      `json:"http2-idle-timeout" yaml:"http2-idle-timeout" toml:"http2-idle-timeout"`
+	AdminToken                string        `json:"admintoken" yaml:"admintoken" toml:"admintoken"`
+	AppName                   string        `json:"appname" yaml:"appname" toml:"appname"`
+	VirtualHosts              []VirtualHost `json:"vhosts" yaml:"vhosts" toml:"vhosts"`
+}
+
+// DefaultConfig returns a Config with the default values.
+func DefaultConfig() Config {
+	serverConfig := webserver.DefaultServerConfig()
+
+	return Config{
+		Addr:               serverConfig.Addr,
+		AppName:            "Go Web Server",
+		ClientAuth:         "none",
+		LogLevel:           "Info",
+		LogType:            "json",
+		AccessLogFormat:    "combined",
+		RequestID:          "counter",
+		RequestIDHeader:    webserver.DefaultRequestIDHeader,
+		ClientIPHeaders:    List(webserver.DefaultClientIPHeaders),
+		ReadTimeout:        Duration(serverConfig.ReadTimeout),
+		WriteTimeout:       Duration(serverConfig.WriteTimeout),
+		IdleTimeout:        Duration(serverConfig.IdleTimeout),
+		ShutdownTimeout:    Duration(serverConfig.ShutdownTimeout),
+		CertWatch:          Duration(serverConfig.CertWatchInterval),
+		ACMEDirectory:      webserver.DefaultACMEDirectory,
+		RedirectStatus:     http.StatusPermanentRedirect,
+		ProxyHeaderTimeout: Duration(serverConfig.ProxyHeaderTimeout),
+	}
+}
+
+// ServerConfig returns the webserver.ServerConfig for c.
+// An error is returned if a setting is invalid.
+func (c Config) ServerConfig() (webserver.ServerConfig, error) {
+	proxyProtocolSources, err := webserver.ParseTrustedProxies(c.ProxyProtocolSources)
+	if err != nil {
+		return webserver.ServerConfig{}, fmt.Errorf("proxyprotocolsources: %w", err)
+	}
+
+	certificates, err := c.CertificateFiles()
+	if err != nil {
+		return webserver.ServerConfig{}, err
+	}
+
+	var listeners []webserver.ListenerConfig
+	for _, spec := range c.Listen {
+		listener, err := webserver.ParseListenerConfig(spec)
+		if err != nil {
+			return webserver.ServerConfig{}, fmt.Errorf("listen: %w", err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	clientAuth, err := webserver.ClientAuth(c.ClientAuth)
+	if err != nil {
+		return webserver.ServerConfig{}, fmt.Errorf("clientauth: %w (valid: %s)", err, webserver.ClientAuthNames())
+	}
+	if clientAuth == tls.RequireAndVerifyClientCert && c.ClientCA == "" {
+		return webserver.ServerConfig{}, errors.New("clientauth verify requires clientca")
+	}
+
+	if c.RedirectStatus != http.StatusMovedPermanently && c.RedirectStatus != http.StatusPermanentRedirect {
+		return webserver.ServerConfig{}, fmt.Errorf("redirect-status: invalid status %d (valid: 301, 308)", c.RedirectStatus)
+	}
+
+	if !httpguts.ValidHeaderFieldName(c.RequestIDHeader) {
+		return webserver.ServerConfig{}, fmt.Errorf("requestidheader: invalid header name %q", c.RequestIDHeader)
+	}
+
+	if c.HTTP2MaxConcurrentStreams > math.MaxUint32 {
+		return webserver.ServerConfig{}, fmt.Errorf("http2-max-concurrent-streams: %d exceeds %d", c.HTTP2MaxConcurrentStreams, uint32(math.MaxUint32))
+	}
+
+	// zero uses the default, otherwise the frame size must be valid for HTTP/2
+	if c.HTTP2MaxFrameSize != 0 && (c.HTTP2MaxFrameSize < 1<<14 || c.HTTP2MaxFrameSize > 1<<24-1) {
+		return webserver.ServerConfig{}, fmt.Errorf("http2-max-frame-size: %d is not between 16384 and 16777215", c.HTTP2MaxFrameSize)
+	}
+
+	if len(c.ACMEDomains) > 0 && (c.CertFile != "" || c.KeyFile != "" || len(certificates) > 0) {
+		return webserver.ServerConfig{}, errors.New("acme-domains cannot be used with certfile, keyfile, or certificates")
+	}
+
+	// acme-http-addr is the former name of redirect-addr
+	redirectAddr := c.RedirectAddr
+	if c.ACMEHTTPAddr != "" {
+		if redirectAddr != "" && redirectAddr != c.ACMEHTTPAddr {
+			return webserver.ServerConfig{}, errors.New("acme-http-addr and redirect-addr differ; use redirect-addr")
+		}
+		redirectAddr = c.ACMEHTTPAddr
+	}
+
+	if c.ACMEWebroot != "" && len(c.ACMEDomains) > 0 {
+		return webserver.ServerConfig{}, errors.New("acme-webroot cannot be used with acme-domains, which answers its own challenges")
+	}
+	if c.ACMEWebroot != "" && redirectAddr == "" {
+		return webserver.ServerConfig{}, errors.New("acme-webroot requires redirect-addr")
+	}
+
+	config := webserver.ServerConfig{
+		Addr:                 c.Addr,
+		Listeners:            listeners,
+		CertFile:             c.CertFile,
+		KeyFile:              c.KeyFile,
+		ReadTimeout:          time.Duration(c.ReadTimeout),
+		WriteTimeout:         time.Duration(c.WriteTimeout),
+		IdleTimeout:          time.Duration(c.IdleTimeout),
+		ShutdownTimeout:      time.Duration(c.ShutdownTimeout),
+		Certificates:         certificates,
+		ClientCAFile:         c.ClientCA,
+		ClientAuth:           clientAuth,
+		CertWatchInterval:    time.Duration(c.CertWatch),
+		ACMEDomains:          c.ACMEDomains,
+		ACMECache:            c.ACMECache,
+		ACMEDirectory:        c.ACMEDirectory,
+		ACMEEmail:            c.ACMEEmail,
+		RedirectAddr:         redirectAddr,
+		RedirectStatus:       c.RedirectStatus,
+		ACMEWebroot:          c.ACMEWebroot,
+		HTTP3Addr:            c.HTTP3Addr,
+		ProxyProtocol:        c.ProxyProtocol,
+		ProxyProtocolSources: proxyProtocolSources,
+		ProxyHeaderTimeout:   time.Duration(c.ProxyHeaderTimeout),
+
+		H2C:                       c.H2C,
+		HTTP2MaxConcurrentStreams: uint32(c.HTTP2MaxConcurrentStreams),
+		HTTP2MaxReadFrameSize:     uint32(c.HTTP2MaxFrameSize),
+		HTTP2IdleTimeout:          time.Duration(c.HTTP2IdleTimeout),
+	}
+
+	for _, listener := range listeners {
+		if listener.TLS && !config.TLS() {
+			return webserver.ServerConfig{}, fmt.Errorf("listen: %s requires certfile and keyfile, certificates, or acme-domains", listener)
+		}
+	}
+
+	if config.RedirectAddr != "" && !config.TLS() {
+		return webserver.ServerConfig{}, errors.New("redirect-addr requires certfile and keyfile, certificates, or acme-domains")
+	}
+
+	if config.HTTP3Addr != "" {
+		if !config.TLS() {
+			return webserver.ServerConfig{}, errors.New("http3-addr requires certfile and keyfile, certificates, or acme-domains")
+		}
+		if _, port, err := net.SplitHostPort(config.HTTP3Addr); err != nil || port == "" {
+			return webserver.ServerConfig{}, fmt.Errorf("http3-addr: invalid address %q", config.HTTP3Addr)
+		}
+	}
+
+	return config, nil
+}
+
+// HSTSConfig returns the webserver.HSTSConfig for c.
+func (c Config) HSTSConfig() webserver.HSTSConfig {
+	return webserver.HSTSConfig{
+		MaxAge:            time.Duration(c.HSTSMaxAge),
+		IncludeSubDomains: c.HSTSIncludeSubDomains,
+		Preload:           c.HSTSPreload,
+	}
+}
+
+// CertificateFiles returns the certificates, which are "certfile:keyfile"
+// pairs, followed by the certificates of the virtual hosts.
+func (c Config) CertificateFiles() ([]webserver.CertificateFiles, error) {
+	var files []webserver.CertificateFiles
+
+	for _, pair := range c.Certificates {
+		certFile, keyFile, ok := strings.Cut(pair, ":")
+		if !ok || certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("certificates: invalid certfile:keyfile %q", pair)
+		}
+		files = append(files, webserver.CertificateFiles{CertFile: certFile, KeyFile: keyFile})
+	}
+
+	for _, vhost := range c.VirtualHosts {
+		if (vhost.CertFile == "") != (vhost.KeyFile == "") {
+			return nil, fmt.Errorf("vhosts: %s: both certfile and keyfile are required", vhost.Host)
+		}
+		if vhost.CertFile != "" {
+			files = append(files, webserver.CertificateFiles{CertFile: vhost.CertFile, KeyFile: vhost.KeyFile})
+		}
+	}
+
+	return files, nil
+}
+
+// LogRotation returns the webserver.LogRotation for c, which applies to both
+// the log file and the access log.
+func (c Config) LogRotation() webserver.LogRotation {
+	return webserver.LogRotation{
+		MaxSize:  int64(c.LogMaxSize) * 1024 * 1024,
+		Daily:    c.LogDaily,
+		MaxFiles: c.LogMaxFiles,
+		Compress: c.LogCompress,
+	}
+}
+
+// LogValue implements slog.LogValuer to log the resolved config.
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("addr", c.Addr),
+		slog.String("listen", c.Listen.String()),
+		slog.String("html", c.HTMLDir),
+		slog.String("logfile", c.LogFile),
+		slog.String("loglevel", c.LogLevel),
+		slog.String("logtype", c.LogType),
+		slog.Bool("logsource", c.LogSource),
+		slog.Int("logmaxsize", c.LogMaxSize),
+		slog.Bool("logdaily", c.LogDaily),
+		slog.Int("logmaxfiles", c.LogMaxFiles),
+		slog.Bool("logcompress", c.LogCompress),
+		slog.String("accesslog", c.AccessLog),
+		slog.String("accesslogformat", c.AccessLogFormat),
+		slog.String("certfile", c.CertFile),
+		slog.String("keyfile", c.KeyFile),
+		slog.String("clientca", c.ClientCA),
+		slog.String("clientauth", c.ClientAuth),
+		slog.String("certificates", c.Certificates.String()),
+		slog.String("certwatch", c.CertWatch.String()),
+		slog.String("acme-domains", c.ACMEDomains.String()),
+		slog.String("acme-cache", c.ACMECache),
+		slog.String("acme-directory", c.ACMEDirectory),
+		slog.String("acme-email", c.ACMEEmail),
+		slog.String("acme-http-addr", c.ACMEHTTPAddr),
+		slog.String("acme-webroot", c.ACMEWebroot),
+		slog.String("redirect-addr", c.RedirectAddr),
+		slog.Int("redirect-status", c.RedirectStatus),
+		slog.String("http3-addr", c.HTTP3Addr),
+		slog.String("hsts-max-age", c.HSTSMaxAge.String()),
+		slog.Bool("hsts-include-subdomains", c.HSTSIncludeSubDomains),
+		slog.Bool("hsts-preload", c.HSTSPreload),
+		slog.String("readtimeout", c.ReadTimeout.String()),
+		slog.String("writetimeout", c.WriteTimeout.String()),
+		slog.String("idletimeout", c.IdleTimeout.String()),
+		slog.String("shutdowntimeout", c.ShutdownTimeout.String()),
+		slog.String("routes", c.Routes.String()),
+		slog.String("requestid", c.RequestID),
+		slog.String("requestidheader", c.RequestIDHeader),
+		slog.Bool("trustrequestid", c.TrustRequestID),
+		slog.String("trustedproxies", c.TrustedProxies.String()),
+		slog.String("clientipheaders", c.ClientIPHeaders.String()),
+		slog.Bool("proxyprotocol", c.ProxyProtocol),
+		slog.String("proxyprotocolsources", c.ProxyProtocolSources.String()),
+		slog.String("proxyheadertimeout", c.ProxyHeaderTimeout.String()),
+		slog.Bool("h2c", c.H2C),
+		slog.Uint64("http2-max-concurrent-streams", uint64(c.HTTP2MaxConcurrentStreams)),
+		slog.Uint64("http2-max-frame-size", uint64(c.HTTP2MaxFrameSize)),
+		slog.String("http2-idle-timeout", c.HTTP2IdleTimeout.String()),
+		slog.Bool("admintoken", c.AdminToken != ""), // do not log the secret
+		slog.String("appname", c.AppName),
+		slog.Any("vhosts", c.VirtualHosts),
+	)
+}
+
+// DefineFlags defines a flag in fs for each setting in c.
+// The current values of c are used as the flag defaults.
+func (c *Config) DefineFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", c.Addr, "[host]:port, using TLS if configured (empty to disable)")
+	fs.Var(&c.Listen, "listen", "comma-separated list of additional listeners, e.g., tcp://127.0.0.1:8080, tcp://:8443?tls=true, or unix:///run/go-webserver.sock?mode=0660&user=www&group=www")
+	fs.StringVar(&c.LogFile, "logfile", c.LogFile, "log file")
+	fs.StringVar(&c.HTMLDir, "html", c.HTMLDir, "html directory (default embedded templates)")
+	fs.StringVar(&c.LogLevel, "loglevel", c.LogLevel, "log level")
+	fs.StringVar(&c.LogType, "logtype", c.LogType, "log type (json|text)")
+	fs.BoolVar(&c.LogSource, "logsource", c.LogSource, "log source code position")
+	fs.IntVar(&c.LogMaxSize, "logmaxsize", c.LogMaxSize, "log file size in megabytes that triggers rotation (default no limit)")
+	fs.BoolVar(&c.LogDaily, "logdaily", c.LogDaily, "rotate log files daily")
+	fs.IntVar(&c.LogMaxFiles, "logmaxfiles", c.LogMaxFiles, "number of rotated log files to keep (default all)")
+	fs.BoolVar(&c.LogCompress, "logcompress", c.LogCompress, "gzip rotated log files")
+	fs.StringVar(&c.AccessLog, "accesslog", c.AccessLog, "access log file (default none)")
+	fs.StringVar(&c.AccessLogFormat, "accesslogformat", c.AccessLogFormat, "access log format (common|combined|directives)")
+	fs.StringVar(&c.CertFile, "certfile", c.CertFile, "certificate file")
+	fs.StringVar(&c.KeyFile, "keyfile", c.KeyFile, "private key file")
+	fs.StringVar(&c.ClientCA, "clientca", c.ClientCA, "PEM file of CAs to verify client certificates")
+	fs.StringVar(&c.ClientAuth, "clientauth", c.ClientAuth, "client certificate mode ("+webserver.ClientAuthNames()+")")
+	fs.Var(&c.Certificates, "certificates", "comma-separated list of additional certfile:keyfile pairs selected by SNI")
+	fs.Var(&c.CertWatch, "certwatch", "interval to check for changed certificate and key files (0 to disable)")
+	fs.Var(&c.ACMEDomains, "acme-domains", "comma-separated list of domains to obtain certificates for using ACME instead of certfile and keyfile")
+	fs.StringVar(&c.ACMECache, "acme-cache", c.ACMECache, "directory to cache ACME accounts and certificates (default none)")
+	fs.StringVar(&c.ACMEDirectory, "acme-directory", c.ACMEDirectory, "ACME directory URL")
+	fs.StringVar(&c.ACMEEmail, "acme-email", c.ACMEEmail, "contact email for the ACME account")
+	fs.StringVar(&c.ACMEHTTPAddr, "acme-http-addr", c.ACMEHTTPAddr, "deprecated: use redirect-addr")
+	fs.StringVar(&c.ACMEWebroot, "acme-webroot", c.ACMEWebroot, "directory of ACME HTTP-01 challenges from an external ACME client, answered on redirect-addr (default none)")
+	fs.StringVar(&c.RedirectAddr, "redirect-addr", c.RedirectAddr, "[host]:port to redirect HTTP to HTTPS and answer ACME HTTP-01 challenges (default none)")
+	fs.IntVar(&c.RedirectStatus, "redirect-status", c.RedirectStatus, "redirect status code (301|308)")
+	fs.StringVar(&c.HTTP3Addr, "http3-addr", c.HTTP3Addr, "[host]:port to serve HTTP/3 over QUIC (UDP), advertised by Alt-Svc (default none)")
+	fs.Var(&c.HSTSMaxAge, "hsts-max-age", "max-age of the Strict-Transport-Security header for HTTPS responses (0 to disable)")
+	fs.BoolVar(&c.HSTSIncludeSubDomains, "hsts-include-subdomains", c.HSTSIncludeSubDomains, "add includeSubDomains to the Strict-Transport-Security header")
+	fs.BoolVar(&c.HSTSPreload, "hsts-preload", c.HSTSPreload, "add preload to the Strict-Transport-Security header")
+	fs.Var(&c.ReadTimeout, "readtimeout", "maximum duration for reading the entire request")
+	fs.Var(&c.WriteTimeout, "writetimeout", "maximum duration before timing out writes of the response")
+	fs.Var(&c.IdleTimeout, "idletimeout", "maximum amount of time to wait for the next request")
+	fs.Var(&c.ShutdownTimeout, "shutdowntimeout", "maximum amount of time to wait for graceful shutdown")
+	fs.Var(&c.Routes, "routes", "comma-separated list of enabled routes (default all except delay and redirect-to)")
+	fs.StringVar(&c.RequestID, "requestid", c.RequestID, "request ID generator ("+webserver.RequestIDGeneratorNames()+")")
+	fs.StringVar(&c.RequestIDHeader, "requestidheader", c.RequestIDHeader, "request ID header")
+	fs.BoolVar(&c.TrustRequestID, "trustrequestid", c.TrustRequestID, "use valid incoming request ID headers")
+	fs.Var(&c.TrustedProxies, "trustedproxies", "comma-separated list of trusted proxy CIDRs or IPs")
+	fs.Var(&c.ClientIPHeaders, "clientipheaders", "comma-separated list of headers, in order of preference, with the client IP from trusted proxies")
+	fs.BoolVar(&c.ProxyProtocol, "proxyprotocol", c.ProxyProtocol, "read PROXY protocol v1/v2 headers from connections")
+	fs.Var(&c.ProxyProtocolSources, "proxyprotocolsources", "comma-separated list of CIDRs or IPs that send PROXY headers (default all)")
+	fs.Var(&c.ProxyHeaderTimeout, "proxyheadertimeout", "maximum duration for reading a PROXY header")
+	fs.BoolVar(&c.H2C, "h2c", c.H2C, "serve HTTP/2 without TLS (h2c) on listeners without TLS")
+	fs.UintVar(&c.HTTP2MaxConcurrentStreams, "http2-max-concurrent-streams", c.HTTP2MaxConcurrentStreams, "maximum concurrent HTTP/2 streams per connection (default 250)")
+	fs.UintVar(&c.HTTP2MaxFrameSize, "http2-max-frame-size", c.HTTP2MaxFrameSize, "largest HTTP/2 frame size to read, 16384 to 16777215 (default 1048576)")
+	fs.Var(&c.HTTP2IdleTimeout, "http2-idle-timeout", "maximum amount of time an HTTP/2 connection can be idle (default idletimeout)")
+	fs.StringVar(&c.AdminToken, "admintoken", c.AdminToken, "bearer token for /admin routes (default admin routes disabled)")
+	fs.StringVar(&c.AppName, "appname", c.AppName, "application name")
+}
+
+// LoadFile reads the config file name into c. The format is determined by the
+// file extension: .json, .yaml, .yml, or .toml. Settings not in the file are
+// left unchanged. Relative paths in the file are relative to its directory.
+func (c *Config) LoadFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("LoadFile: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), c)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys: %v", md.Undecoded())
+		}
+	default:
+		err = fmt.Errorf("unsupported config file extension: %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("LoadFile: %s: %w", name, err)
+	}
+
+	dir := filepath.Dir(name)
+	paths := []*string{&c.HTMLDir, &c.LogFile, &c.AccessLog, &c.CertFile, &c.KeyFile, &c.ClientCA, &c.ACMECache, &c.ACMEWebroot}
+	for i := range c.VirtualHosts {
+		paths = append(paths, &c.VirtualHosts[i].CertFile, &c.VirtualHosts[i].KeyFile)
+	}
+	for _, path := range paths {
+		resolvePath(dir, path)
+	}
+
+	for i, pair := range c.Certificates {
+		if certFile, keyFile, ok := strings.Cut(pair, ":"); ok {
+			resolvePath(dir, &certFile)
+			resolvePath(dir, &keyFile)
+			c.Certificates[i] = certFile + ":" + keyFile
+		}
+	}
+
+	return nil
+}
+
+// resolvePath makes path, if relative and not empty, relative to dir.
+func resolvePath(dir string, path *string) {
+	if *path != "" && !filepath.IsAbs(*path) {
+		*path = filepath.Join(dir, *path)
+	}
+}
+
+// EnvPrefix is the prefix of the environment variables that override settings.
+const EnvPrefix = "GOWEBSERVER_"
+
+// EnvName returns the environment variable name for the flag name,
+// e.g., GOWEBSERVER_ADDR for addr.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets each flag in fs, other than those in skip, from its
+// environment variable, if present. The values are validated by the flag.
+func applyEnv(fs *flag.FlagSet, skip ...string) error {
+	var err error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || slices.Contains(skip, f.Name) {
+			return
+		}
+
+		name := EnvName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, name, setErr)
+		}
+	})
+
+	return err
+}
+
+// ParseConfig returns the Config resolved from, in increasing order of
+// precedence, the defaults, the config file, the environment variables, and
+// the command-line flags in args. The flags are defined in fs and the usage
+// of each flag lists its environment variable. The config file is given by
+// the -config flag or the GOWEBSERVER_CONFIG environment variable.
+func ParseConfig(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := DefaultConfig()
+	cfg.DefineFlags(fs)
+	configFile := fs.String("config", "", "config file (.json, .yaml, .yml, or .toml)")
+
+	// list the environment variable with each flag
+	fs.VisitAll(func(f *flag.Flag) {
+		f.Usage += " [$" + EnvName(f.Name) + "]"
+	})
+
+	// parse flags to find the config file
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv(EnvName("config"))
+	}
+
+	if *configFile != "" {
+		if err := cfg.LoadFile(*configFile); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := applyEnv(fs, "config"); err != nil {
+		return cfg, err
+	}
+
+	// parse flags again so they take precedence over the file and environment
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
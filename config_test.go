@@ -0,0 +1,419 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"config.json":  `{"addr": ":9090", "logtype": "text", "certfile": "server.crt", "readtimeout": "7s", "routes": ["hello", "build"]}`,
+		"config.yaml":  "addr: \":9090\"\nlogtype: text\ncertfile: server.crt\nreadtimeout: 7s\nroutes: [hello, build]\n",
+		"config.toml":  "addr = \":9090\"\nlogtype = \"text\"\ncertfile = \"server.crt\"\nreadtimeout = \"7s\"\nroutes = [\"hello\", \"build\"]\n",
+		"unknown.yaml": "port: 9090\n",
+		"config.ini":   "addr = :9090\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fromFile := DefaultConfig()
+	fromFile.Addr = ":9090"
+	fromFile.LogType = "text"
+	fromFile.CertFile = filepath.Join(dir, "server.crt")
+	fromFile.ReadTimeout = Duration(7 * time.Second)
+	fromFile.Routes = List{"hello", "build"}
+
+	fromEnv := fromFile
+	fromEnv.Addr = ":7070"
+	fromEnv.LogSource = true
+	fromEnv.IdleTimeout = Duration(time.Minute)
+
+	fromFlags := fromFile
+	fromFlags.Addr = ":8000"
+	fromFlags.CertFile = "flag.crt"
+	fromFlags.Routes = List{"headers"}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "Defaults",
+			want: DefaultConfig(),
+		},
+		{
+			name: "JSON file",
+			args: []string{"-config", filepath.Join(dir, "config.json")},
+			want: fromFile,
+		},
+		{
+			name: "YAML file",
+			args: []string{"-config", filepath.Join(dir, "config.yaml")},
+			want: fromFile,
+		},
+		{
+			name: "TOML file",
+			args: []string{"-config", filepath.Join(dir, "config.toml")},
+			want: fromFile,
+		},
+		{
+			name: "Flags override file",
+			args: []string{"-addr", ":8000", "-config", filepath.Join(dir, "config.yaml"), "-certfile", "flag.crt", "-routes", "headers"},
+			want: fromFlags,
+		},
+		{
+			name: "Environment overrides file",
+			args: []string{"-config", filepath.Join(dir, "config.yaml")},
+			env: map[string]string{
+				"GOWEBSERVER_ADDR":        ":7070",
+				"GOWEBSERVER_LOGSOURCE":   "true",
+				"GOWEBSERVER_IDLETIMEOUT": "1m",
+			},
+			want: fromEnv,
+		},
+		{
+			name: "Environment config file",
+			env:  map[string]string{"GOWEBSERVER_CONFIG": filepath.Join(dir, "config.toml")},
+			want: fromFile,
+		},
+		{
+			name: "Flags override environment",
+			args: []string{"-addr", ":8000", "-certfile", "flag.crt", "-routes", "headers"},
+			env: map[string]string{
+				"GOWEBSERVER_CONFIG": filepath.Join(dir, "config.json"),
+				"GOWEBSERVER_ADDR":   ":7070",
+			},
+			want: fromFlags,
+		},
+		{
+			name:    "Invalid duration environment",
+			env:     map[string]string{"GOWEBSERVER_READTIMEOUT": "5"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid bool environment",
+			env:     map[string]string{"GOWEBSERVER_LOGSOURCE": "maybe"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown key",
+			args:    []string{"-config", filepath.Join(dir, "unknown.yaml")},
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported extension",
+			args:    []string{"-config", filepath.Join(dir, "config.ini")},
+			wantErr: true,
+		},
+		{
+			name:    "Missing file",
+			args:    []string{"-config", filepath.Join(dir, "missing.json")},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid duration flag",
+			args:    []string{"-idletimeout", "forever"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+
+			got, err := ParseConfig(fs, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected config %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigServerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{
+			name:   "Defaults",
+			modify: func(c *Config) {},
+		},
+		{
+			name: "ACME",
+			modify: func(c *Config) {
+				c.ACMEDomains = List{"example.com"}
+			},
+		},
+		{
+			name: "ACME with certificate",
+			modify: func(c *Config) {
+				c.ACMEDomains = List{"example.com"}
+				c.CertFile = "server.crt"
+				c.KeyFile = "server.key"
+			},
+			wantErr: true,
+		},
+		{
+			name: "Certificates",
+			modify: func(c *Config) {
+				c.Certificates = List{"diag.crt:diag.key"}
+				c.VirtualHosts = []VirtualHost{{Host: "echo.example.com", CertFile: "echo.crt", KeyFile: "echo.key"}}
+			},
+		},
+		{
+			name: "Invalid certificates",
+			modify: func(c *Config) {
+				c.Certificates = List{"diag.crt"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "Virtual host without keyfile",
+			modify: func(c *Config) {
+				c.VirtualHosts = []VirtualHost{{Host: "echo.example.com", CertFile: "echo.crt"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "ACME with certificates",
+			modify: func(c *Config) {
+				c.ACMEDomains = List{"example.com"}
+				c.Certificates = List{"diag.crt:diag.key"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "Client auth verify",
+			modify: func(c *Config) {
+				c.ClientAuth = "verify"
+				c.ClientCA = "ca.crt"
+			},
+		},
+		{
+			name: "Client auth verify without client CA",
+			modify: func(c *Config) {
+				c.ClientAuth = "verify"
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid client auth",
+			modify: func(c *Config) {
+				c.ClientAuth = "invalid"
+			},
+			wantErr: true,
+		},
+		{
+			name: "Redirect",
+			modify: func(c *Config) {
+				c.RedirectAddr = ":80"
+				c.CertFile = "server.crt"
+				c.KeyFile = "server.key"
+			},
+		},
+		{
+			name: "Redirect without TLS",
+			modify: func(c *Config) {
+				c.RedirectAddr = ":80"
+			},
+			wantErr: true,
+		},
+		{
+			name: "HTTP/3",
+			modify: func(c *Config) {
+				c.HTTP3Addr = ":8443"
+				c.CertFile = "server.crt"
+				c.KeyFile = "server.key"
+			},
+		},
+		{
+			name: "HTTP/3 without TLS",
+			modify: func(c *Config) {
+				c.HTTP3Addr = ":8443"
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid HTTP/3 address",
+			modify: func(c *Config) {
+				c.HTTP3Addr = "8443"
+				c.CertFile = "server.crt"
+				c.KeyFile = "server.key"
+			},
+			wantErr: true,
+		},
+		{
+			name: "Former name of redirect-addr",
+			modify: func(c *Config) {
+				c.ACMEHTTPAddr = ":80"
+				c.CertFile = "server.crt"
+				c.KeyFile = "server.key"
+			},
+		},
+		{
+			name: "Different redirect-addr and acme-http-addr",
+			modify: func(c *Config) {
+				c.ACMEHTTPAddr = ":80"
+				c.RedirectAddr = ":8080"
+				c.CertFile = "server.crt"
+				c.KeyFile = "server.key"
+			},
+			wantErr: true,
+		},
+		{
+			name: "ACME webroot",
+			modify: func(c *Config) {
+				c.ACMEWebroot = "/var/www/acme"
+				c.RedirectAddr = ":80"
+				c.CertFile = "server.crt"
+				c.KeyFile = "server.key"
+			},
+		},
+		{
+			name: "ACME webroot without redirect-addr",
+			modify: func(c *Config) {
+				c.ACMEWebroot = "/var/www/acme"
+				c.CertFile = "server.crt"
+				c.KeyFile = "server.key"
+			},
+			wantErr: true,
+		},
+		{
+			name: "ACME webroot with acme-domains",
+			modify: func(c *Config) {
+				c.ACMEWebroot = "/var/www/acme"
+				c.ACMEDomains = List{"example.com"}
+				c.RedirectAddr = ":80"
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid redirect status",
+			modify: func(c *Config) {
+				c.RedirectStatus = http.StatusFound
+			},
+			wantErr: true,
+		},
+		{
+			name: "Empty request ID header",
+			modify: func(c *Config) {
+				c.RequestIDHeader = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid request ID header",
+			modify: func(c *Config) {
+				c.RequestIDHeader = "X-Request ID"
+			},
+			wantErr: true,
+		},
+		{
+			name: "Request ID header",
+			modify: func(c *Config) {
+				c.RequestIDHeader = "X-Correlation-ID"
+			},
+		},
+		{
+			name: "Listeners",
+			modify: func(c *Config) {
+				c.Listen = List{"127.0.0.1:8081", "unix:///tmp/go-webserver.sock?mode=0660"}
+			},
+		},
+		{
+			name: "Invalid listener",
+			modify: func(c *Config) {
+				c.Listen = List{"udp://:8081"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "TLS listener without TLS",
+			modify: func(c *Config) {
+				c.Listen = List{"tcp://:8443?tls=true"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid proxy protocol source",
+			modify: func(c *Config) {
+				c.ProxyProtocolSources = List{"invalid"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "HTTP/2 settings",
+			modify: func(c *Config) {
+				c.H2C = true
+				c.HTTP2MaxConcurrentStreams = 100
+				c.HTTP2MaxFrameSize = 1 << 14
+			},
+		},
+		{
+			name: "HTTP/2 frame size too small",
+			modify: func(c *Config) {
+				c.HTTP2MaxFrameSize = 1024
+			},
+			wantErr: true,
+		},
+		{
+			name: "HTTP/2 frame size too large",
+			modify: func(c *Config) {
+				c.HTTP2MaxFrameSize = 1 << 24
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultConfig()
+			tt.modify(&c)
+
+			_, err := c.ServerConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
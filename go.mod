@@ -1,3 +1,17 @@
 module github.com/bnixon67/go-webserver
 
-go 1.21.0
+go 1.24
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/quic-go/quic-go v0.59.1
+	golang.org/x/crypto v0.41.0
+	golang.org/x/net v0.43.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/quic-go/qpack v0.6.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+)
@@ -15,18 +15,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"html/template"
 	"log/slog"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/bnixon67/go-webserver/webserver"
 )
 
 const (
@@ -36,138 +40,326 @@ const (
 	ExitTemplate     // ExitTemplate indicates a template error.
 )
 
-// ServerConfig holds configuration options for the HTTP server.
-type ServerConfig struct {
-	Addr         string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	IdleTimeout  time.Duration
-}
+func main() {
+	// resolve config from defaults, config file, environment, and flags
+	cfg, err := ParseConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
 
-// createServer creates an HTTP server with the specified config and handler.
-func createServer(config ServerConfig, handler http.Handler) *http.Server {
-	return &http.Server{
-		Addr:         config.Addr,
-		Handler:      handler,
-		ReadTimeout:  config.ReadTimeout,
-		WriteTimeout: config.WriteTimeout,
-		IdleTimeout:  config.IdleTimeout,
+	// get slog.Level from config
+	logLevel, err := webserver.LogLevel(cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Fprintf(os.Stderr, "valid loglevels: %s\n", webserver.LogLevels())
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	// validate logtype
+	if !slices.Contains(webserver.ValidLogTypes, cfg.LogType) {
+		fmt.Fprintf(os.Stderr, "invalid logtype: %v\n", cfg.LogType)
+		fmt.Fprintf(os.Stderr, "valid logtypes: %s\n", strings.Join(webserver.ValidLogTypes, ", "))
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	// check for additional command-line arguments
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(ExitUsage)
 	}
-}
 
-// runServer starts the HTTP server and handles graceful shutdown.
-func runServer(ctx context.Context, srv *http.Server, certFile, keyFile string) {
-	ln, err := net.Listen("tcp", srv.Addr)
+	// initialize logging
+	err = webserver.InitLog(cfg.LogFile, cfg.LogType, logLevel, cfg.LogSource, cfg.LogRotation())
 	if err != nil {
-		slog.Error("failed to listen", "err", err)
-		os.Exit(ExitServer)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(ExitLog)
 	}
 
-	go func() {
-		if certFile != "" && keyFile != "" {
-			err = srv.ServeTLS(ln, certFile, keyFile)
-		} else {
-			err = srv.Serve(ln)
-		}
-		if err != nil && err != http.ErrServerClosed {
-			slog.Error("failed to serve", "err", err)
-			os.Exit(ExitServer)
+	slog.Info("resolved config", "config", cfg)
+
+	// initialize templates
+	tmpl, err := initTemplates(cfg.HTMLDir)
+	if err != nil {
+		slog.Error("failed to initialize templates", "err", err)
+		os.Exit(ExitTemplate)
+	}
+
+	h := webserver.NewHandler(cfg.AppName, tmpl)
+
+	// select the enabled routes
+	mux, err := newServeMux(h.Routes(), cfg.Routes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Fprintf(os.Stderr, "valid routes: %s\n", strings.Join(webserver.RouteNames(h.Routes()), ", "))
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	// each virtual host has its own handler and routes, and other hosts use mux
+	handlers := []*webserver.Handler{h}
+	muxes := []*http.ServeMux{mux}
+	hostMux := webserver.NewHostMux(mux)
+	for _, vhost := range cfg.VirtualHosts {
+		if vhost.Host == "" {
+			fmt.Fprintf(os.Stderr, "vhosts: host is required\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
 		}
-	}()
 
-	slog.Info("started server", slog.String("addr", ln.Addr().String()))
+		appName := vhost.AppName
+		if appName == "" {
+			appName = cfg.AppName
+		}
+		vh := webserver.NewHandler(appName, tmpl)
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		vmux, err := newServeMux(vh.VirtualHostRoutes(), vhost.Routes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "vhosts: %s: %v\n", vhost.Host, err)
+			fmt.Fprintf(os.Stderr, "valid routes: %s\n", strings.Join(webserver.RouteNames(vh.VirtualHostRoutes()), ", "))
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
 
-	select {
-	case sig := <-sigChan:
-		signal.Stop(sigChan)
+		handlers = append(handlers, vh)
+		muxes = append(muxes, vmux)
+		hostMux.Handle(vhost.Host, vmux)
+	}
 
-		slog.Info("shutting down server", "signal", sig)
+	// admin routes are only enabled if a token is configured
+	if cfg.AdminToken != "" {
+		for _, m := range muxes {
+			webserver.RegisterRoutes(m, h.AdminRoutes(cfg.AdminToken))
+		}
+	}
 
-		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		defer cancel()
+	var handler http.Handler = webserver.LogRequest(hostMux)
 
-		err := srv.Shutdown(timeoutCtx)
+	// write an access log, if requested
+	var accessLogFile *webserver.LogFile
+	if cfg.AccessLog != "" {
+		accessLogFile, err = webserver.OpenLogFile(cfg.AccessLog, cfg.LogRotation())
 		if err != nil {
-			slog.Error("server shutdown error", "err", err)
+			slog.Error("failed to open access log", "err", err)
+			os.Exit(ExitLog)
 		}
+		defer accessLogFile.Close()
 
-		slog.Info("server shutdown")
-	case <-ctx.Done():
+		accessLog, err := webserver.NewAccessLog(accessLogFile, cfg.AccessLogFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		handler = accessLog.LogAccess(handler)
 	}
-}
 
-func main() {
-	// define command-line flags
-	addrFlag := flag.String("addr", ":8080", "[host]:port")
-	logFileFlag := flag.String("logfile", "", "log file")
-	htmlDirFlag := flag.String("html", "html", "html directory")
-	logLevelFlag := flag.String("loglevel", "Info", "log level")
-	logTypeFlag := flag.String("logtype", "json", "log type (json|text)")
-	logAddSource := flag.Bool("logsource", false, "log source code position")
-	certFileFlag := flag.String("certfile", "", "certificate file")
-	keyFileFlag := flag.String("keyfile", "", "private key file")
-
-	// parse command-line flags
-	flag.Parse()
-
-	// get slog.Level from flag
-	logLevel, err := LogLevel(*logLevelFlag)
+	generator, err := webserver.NewRequestIDGenerator(cfg.RequestID)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
-		fmt.Fprintf(os.Stderr, "valid loglevels: %s\n", LogLevels())
+		fmt.Fprintf(os.Stderr, "valid request ID generators: %s\n", webserver.RequestIDGeneratorNames())
 		flag.Usage()
 		os.Exit(ExitUsage)
 	}
 
-	// validate logtype
-	if !slices.Contains(validLogTypes, *logTypeFlag) {
-		fmt.Fprintf(os.Stderr, "invalid logtype: %v\n", *logTypeFlag)
-		fmt.Fprintf(os.Stderr, "valid logtypes: %s\n", strings.Join(validLogTypes, ", "))
+	trustedProxies, err := webserver.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		flag.Usage()
 		os.Exit(ExitUsage)
 	}
+	proxyConfig := webserver.ProxyConfig{
+		TrustedProxies: trustedProxies,
+		Headers:        cfg.ClientIPHeaders,
+	}
 
-	// check for additional command-line arguments
-	if flag.NArg() > 0 {
+	requestIDConfig := webserver.RequestIDConfig{
+		TrustIncoming: cfg.TrustRequestID,
+		Generator:     generator,
+		Header:        cfg.RequestIDHeader,
+	}
+	serverConfig, err := cfg.ServerConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		flag.Usage()
 		os.Exit(ExitUsage)
 	}
 
-	// initialize logging
-	err = InitLog(*logFileFlag, *logTypeFlag, logLevel, *logAddSource)
+	handler = cfg.HSTSConfig().AddHSTS(handler)
+
+	srv := webserver.NewServer(serverConfig, requestIDConfig.AddRequestID(proxyConfig.AddClientInfo(handler)))
+
+	// the metrics route of the default host reports the server certificates
+	h.Certificates = srv.Certificates()
+
+	if cfg.AdminToken != "" {
+		for _, m := range muxes {
+			webserver.RegisterRoutes(m, srv.AdminRoutes(cfg.AdminToken))
+		}
+	}
+
+	// use the listeners from systemd socket activation or an upgrade, if any
+	inherited, err := webserver.SystemdListeners()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(ExitLog)
+		slog.Error("failed to inherit listeners", "err", err)
+		os.Exit(ExitServer)
+	}
+	upgradeListeners, upgradeReady, err := webserver.UpgradeListeners()
+	if err != nil {
+		slog.Error("failed to inherit listeners", "err", err)
+		os.Exit(ExitServer)
 	}
+	srv.Inherit(append(inherited, upgradeListeners...))
 
-	// initialize templates
-	tmpl, err := InitTemplates(filepath.Join(*htmlDirFlag, "*.html"))
+	// notify systemd, if it started the server, of the server state
+	var upgraded atomic.Bool
+	srv.OnReady = func(listeners []webserver.Listener) {
+		addrs := make([]string, 0, len(listeners))
+		for _, l := range listeners {
+			addrs = append(addrs, l.Addr().String())
+		}
+		status := "STATUS=serving on " + strings.Join(addrs, ", ")
+
+		// the previous process notifies systemd that this is the main process
+		if upgradeReady != nil {
+			if err := upgradeReady(); err != nil {
+				slog.Error("failed to notify previous process", "err", err)
+			}
+			notify(status)
+			return
+		}
+		notify("READY=1\n" + status)
+	}
+	srv.OnShutdown = func() {
+		if !upgraded.Load() {
+			notify("STOPPING=1\nSTATUS=shutting down")
+		}
+	}
+
+	// shutdown the server gracefully on interrupt or terminate
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// shutdown the server gracefully once a new process is serving
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// start a new process of the executable, e.g., a new build, on SIGTTIN
+	upgradeChan := make(chan os.Signal, 1)
+	signal.Notify(upgradeChan, syscall.SIGTTIN)
+	defer signal.Stop(upgradeChan)
+	go func() {
+		for range upgradeChan {
+			slog.Info("upgrading")
+			pid, err := srv.Upgrade()
+			if err != nil {
+				slog.Error("failed to upgrade", "err", err)
+				continue
+			}
+
+			slog.Info("upgraded", "pid", pid)
+			upgraded.Store(true)
+			notify(fmt.Sprintf("MAINPID=%d\nSTATUS=upgraded to process %d", pid, pid))
+			cancel(errors.New("upgraded"))
+			return
+		}
+	}()
+
+	go webserver.RunWatchdog(ctx)
+
+	// reload on hangup
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
+	go func() {
+		for range hupChan {
+			reload(handlers, srv, cfg.HTMLDir, accessLogFile)
+		}
+	}()
+
+	// step the log level up on SIGUSR1 and down on SIGUSR2
+	usrChan := make(chan os.Signal, 1)
+	signal.Notify(usrChan, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(usrChan)
+	go func() {
+		for sig := range usrChan {
+			steps := 1
+			if sig == syscall.SIGUSR2 {
+				steps = -1
+			}
+			webserver.StepLogLevel(steps, "signal "+sig.String())
+		}
+	}()
+
+	err = srv.Run(ctx)
 	if err != nil {
-		slog.Error("failed to InitTemplates", "err", err)
-		os.Exit(ExitTemplate)
+		slog.Error("failed to run server", "err", err)
+		os.Exit(ExitServer)
+	}
+}
+
+// initTemplates parses the templates in htmlDir, or the embedded templates
+// if htmlDir is empty.
+func initTemplates(htmlDir string) (*template.Template, error) {
+	if htmlDir == "" {
+		return webserver.DefaultTemplates()
+	}
+	return webserver.InitTemplates(filepath.Join(htmlDir, "*.html"))
+}
+
+// notify sends state to systemd, if it started the server, and logs any error.
+func notify(state string) {
+	if _, err := webserver.SdNotify(state); err != nil {
+		slog.Warn("failed to notify systemd", "err", err)
 	}
+}
 
-	h := NewHandler("Go Web Server", tmpl)
+// newServeMux returns a ServeMux with the routes whose names are in
+// names, or all routes if names is empty.
+func newServeMux(routes []webserver.Route, names []string) (*http.ServeMux, error) {
+	selected, err := webserver.SelectRoutes(routes, names)
+	if err != nil {
+		return nil, err
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", h.RootHandler)
-	mux.HandleFunc("/hello", h.HelloHandler)
-	mux.HandleFunc("/hellohtml", h.HelloHTMLHandler)
-	mux.HandleFunc("/headers", h.HeadersHandler)
-	mux.HandleFunc("/remote", h.RemoteHandler)
-	mux.HandleFunc("/request", h.RequestHandler)
-	mux.HandleFunc("/build", h.BuildHandler)
-
-	ctx := context.Background()
-	serverConfig := ServerConfig{
-		Addr:         *addrFlag,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
-	srv := createServer(serverConfig, h.AddRequestID(h.LogRequest(mux)))
-	runServer(ctx, srv, *certFileFlag, *keyFileFlag)
+	webserver.RegisterRoutes(mux, selected)
+
+	return mux, nil
+}
+
+// reload reopens the log files, reparses the templates for the handlers, and
+// reloads the TLS certificates. If any of these fail, the error is logged and
+// the previous state is kept.
+func reload(handlers []*webserver.Handler, srv *webserver.Server, htmlDir string, accessLogFile *webserver.LogFile) {
+	slog.Info("reloading")
+
+	if err := webserver.ReopenLog(); err != nil {
+		slog.Error("failed to reopen log", "err", err)
+	}
+
+	if accessLogFile != nil {
+		if err := accessLogFile.Reopen(); err != nil {
+			slog.Error("failed to reopen access log", "err", err)
+		}
+	}
+
+	tmpl, err := initTemplates(htmlDir)
+	if err != nil {
+		slog.Error("failed to reload templates", "err", err)
+	} else {
+		for _, h := range handlers {
+			h.SetTmpl(tmpl)
+		}
+	}
+
+	if err := srv.ReloadCertificate(); err != nil {
+		slog.Error("failed to reload certificate", "err", err)
+	}
+
+	slog.Info("reloaded")
+	notify("STATUS=reloaded at " + time.Now().Format(time.RFC3339))
 }
@@ -0,0 +1,279 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Predefined access log formats, as used by the Apache HTTP Server.
+const (
+	CommonLogFormat   = `%h %l %u %t "%r" %>s %b`
+	CombinedLogFormat = CommonLogFormat + ` "%{Referer}i" "%{User-Agent}i"`
+)
+
+// AccessLogFormats maps names to predefined access log formats.
+var AccessLogFormats = map[string]string{
+	"common":   CommonLogFormat,
+	"combined": CombinedLogFormat,
+}
+
+// accessLogEntry holds the details of a completed request.
+type accessLogEntry struct {
+	r        *http.Request
+	rw       *ResponseWriter
+	start    time.Time
+	duration time.Duration
+}
+
+// accessLogField appends a formatted field of e to b.
+type accessLogField func(b []byte, e *accessLogEntry) []byte
+
+// AccessLog writes a line for each completed request in a format defined by
+// Apache-style directives.
+type AccessLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	fields []accessLogField
+}
+
+// NewAccessLog returns an AccessLog that writes to w using format, which is
+// either a name in AccessLogFormats or a string of directives.
+//
+// The supported directives are:
+//
+//	%%         a literal percent sign
+//	%a         client IP address of the request
+//	%b         size of the response body in bytes, or "-" if zero
+//	%B         size of the response body in bytes
+//	%D         time taken to serve the request, in microseconds
+//	%h         remote host, i.e., client IP address
+//	%H         request protocol
+//	%l         remote logname, always "-"
+//	%m         request method
+//	%q         query string, prefixed with "?" if not empty
+//	%r         first line of the request
+//	%s, %>s    response status
+//	%t         time the request was received
+//	%T         time taken to serve the request, in seconds
+//	%u         remote user from basic authentication, or "-"
+//	%U         URL path requested
+//	%v         host requested
+//	%{Name}i   request header Name
+//	%{Name}o   response header Name
+//	%{Name}x   variable Name, which is PROTOCOL for the connection protocol,
+//	           e.g., h2 or h2c; see Protocol
+func NewAccessLog(w io.Writer, format string) (*AccessLog, error) {
+	if named, ok := AccessLogFormats[strings.ToLower(format)]; ok {
+		format = named
+	}
+
+	fields, err := parseAccessLogFormat(format)
+	if err != nil {
+		return nil, fmt.Errorf("NewAccessLog: %w", err)
+	}
+
+	return &AccessLog{w: w, fields: fields}, nil
+}
+
+// parseAccessLogFormat returns the fields defined by the directives in format.
+func parseAccessLogFormat(format string) ([]accessLogField, error) {
+	var fields []accessLogField
+
+	for len(format) > 0 {
+		// literal text up to the next directive
+		i := strings.IndexByte(format, '%')
+		if i < 0 {
+			i = len(format)
+		}
+		if i > 0 {
+			literal := format[:i]
+			fields = append(fields, func(b []byte, _ *accessLogEntry) []byte {
+				return append(b, literal...)
+			})
+			format = format[i:]
+			continue
+		}
+
+		// directive, with optional "{Name}" argument and ">" modifier
+		format = format[1:]
+		var arg string
+		if strings.HasPrefix(format, "{") {
+			end := strings.IndexByte(format, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated directive argument: %%%s", format)
+			}
+			arg = format[1:end]
+			format = format[end+1:]
+		}
+		format = strings.TrimPrefix(format, ">")
+		if format == "" {
+			return nil, fmt.Errorf("incomplete directive at end of format")
+		}
+
+		field, err := accessLogDirective(format[0], arg)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		format = format[1:]
+	}
+
+	return fields, nil
+}
+
+// accessLogDirective returns the field for the directive c with argument arg.
+func accessLogDirective(c byte, arg string) (accessLogField, error) {
+	if arg != "" && c != 'i' && c != 'o' && c != 'x' {
+		return nil, fmt.Errorf("unexpected argument for directive: %%{%s}%c", arg, c)
+	}
+
+	switch c {
+	case '%':
+		return func(b []byte, _ *accessLogEntry) []byte { return append(b, '%') }, nil
+	case 'a', 'h':
+		return func(b []byte, e *accessLogEntry) []byte { return appendEscaped(b, RealIP(e.r)) }, nil
+	case 'b':
+		return func(b []byte, e *accessLogEntry) []byte {
+			if e.rw.BytesWritten() == 0 {
+				return append(b, '-')
+			}
+			return strconv.AppendInt(b, e.rw.BytesWritten(), 10)
+		}, nil
+	case 'B':
+		return func(b []byte, e *accessLogEntry) []byte { return strconv.AppendInt(b, e.rw.BytesWritten(), 10) }, nil
+	case 'D':
+		return func(b []byte, e *accessLogEntry) []byte { return strconv.AppendInt(b, e.duration.Microseconds(), 10) }, nil
+	case 'H':
+		return func(b []byte, e *accessLogEntry) []byte { return appendEscaped(b, e.r.Proto) }, nil
+	case 'l':
+		return func(b []byte, _ *accessLogEntry) []byte { return append(b, '-') }, nil
+	case 'm':
+		return func(b []byte, e *accessLogEntry) []byte { return appendEscaped(b, e.r.Method) }, nil
+	case 'q':
+		return func(b []byte, e *accessLogEntry) []byte {
+			if e.r.URL.RawQuery == "" {
+				return b
+			}
+			return appendEscaped(append(b, '?'), e.r.URL.RawQuery)
+		}, nil
+	case 'r':
+		return func(b []byte, e *accessLogEntry) []byte {
+			return appendEscaped(b, e.r.Method+" "+e.r.URL.RequestURI()+" "+e.r.Proto)
+		}, nil
+	case 's':
+		return func(b []byte, e *accessLogEntry) []byte { return strconv.AppendInt(b, int64(e.rw.Status()), 10) }, nil
+	case 't':
+		return func(b []byte, e *accessLogEntry) []byte {
+			return e.start.AppendFormat(append(b, '['), "02/Jan/2006:15:04:05 -0700]")
+		}, nil
+	case 'T':
+		return func(b []byte, e *accessLogEntry) []byte {
+			return strconv.AppendInt(b, int64(e.duration/time.Second), 10)
+		}, nil
+	case 'u':
+		return func(b []byte, e *accessLogEntry) []byte {
+			user, _, ok := e.r.BasicAuth()
+			if !ok || user == "" {
+				return append(b, '-')
+			}
+			return appendEscaped(b, user)
+		}, nil
+	case 'U':
+		return func(b []byte, e *accessLogEntry) []byte { return appendEscaped(b, e.r.URL.EscapedPath()) }, nil
+	case 'v':
+		return func(b []byte, e *accessLogEntry) []byte { return appendEscaped(b, e.r.Host) }, nil
+	case 'i':
+		if arg == "" {
+			return nil, fmt.Errorf("missing header name for directive: %%i")
+		}
+		return func(b []byte, e *accessLogEntry) []byte { return appendHeader(b, e.r.Header, arg) }, nil
+	case 'o':
+		if arg == "" {
+			return nil, fmt.Errorf("missing header name for directive: %%o")
+		}
+		return func(b []byte, e *accessLogEntry) []byte { return appendHeader(b, e.rw.Header(), arg) }, nil
+	case 'x':
+		if arg != "PROTOCOL" {
+			return nil, fmt.Errorf("unknown variable for directive: %%{%s}x", arg)
+		}
+		return func(b []byte, e *accessLogEntry) []byte { return appendEscaped(b, Protocol(e.r)) }, nil
+	}
+
+	return nil, fmt.Errorf("unknown directive: %%%c", c)
+}
+
+// appendHeader appends the values of the named header, or "-" if not present.
+func appendHeader(b []byte, header http.Header, name string) []byte {
+	values := header.Values(name)
+	if len(values) == 0 {
+		return append(b, '-')
+	}
+	return appendEscaped(b, strings.Join(values, ", "))
+}
+
+// appendEscaped appends s to b, escaping quotes, backslashes, and
+// non-printable characters as done by the Apache HTTP Server.
+func appendEscaped(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < 0x20 || c >= 0x7f:
+			b = append(b, '\\', 'x', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+
+	return b
+}
+
+// LogAccess is middleware that writes a line to the access log for each
+// completed request.
+func (l *AccessLog) LogAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := accessLogEntry{r: r, rw: NewResponseWriter(w), start: time.Now()}
+
+		next.ServeHTTP(e.rw, r)
+
+		e.duration = time.Since(e.start)
+		l.write(&e)
+	})
+}
+
+// write formats e and writes it as a single line to the access log.
+func (l *AccessLog) write(e *accessLogEntry) {
+	b := make([]byte, 0, 256)
+	for _, field := range l.fields {
+		b = field(b, e)
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.w.Write(b); err != nil {
+		Logger(e.r.Context()).Error("failed to write access log", "err", err)
+	}
+}
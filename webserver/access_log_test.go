@@ -0,0 +1,85 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		expected *regexp.Regexp
+	}{
+		{
+			name:     "Common",
+			format:   "common",
+			expected: regexp.MustCompile(`^192\.0\.2\.1 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\] "GET /path\?q=1 HTTP/1\.1" 201 5\n$`),
+		},
+		{
+			name:     "Combined",
+			format:   "Combined",
+			expected: regexp.MustCompile(`^192\.0\.2\.1 - alice \[.+\] "GET /path\?q=1 HTTP/1\.1" 201 5 "http://example\.com/" "agent \\"quoted\\""\n$`),
+		},
+		{
+			name:     "Directives",
+			format:   `%m %U%q %H %{PROTOCOL}x %s %B %{X-Test}o %{Missing}i %v %% done`,
+			expected: regexp.MustCompile(`^GET /path\?q=1 HTTP/1\.1 http/1\.1 201 5 out - example\.com % done\n$`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, err := NewAccessLog(&buf, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			h := l.LogAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "out")
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, "hello")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/path?q=1", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.SetBasicAuth("alice", "secret")
+			req.Header.Set("Referer", "http://example.com/")
+			req.Header.Set("User-Agent", `agent "quoted"`)
+
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !tt.expected.MatchString(buf.String()) {
+				t.Errorf("expected match for %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestNewAccessLogInvalid(t *testing.T) {
+	formats := []string{"%", "%z", "%{Name", "%{Name}s", "%i", "%x", "%{Name}x"}
+
+	for _, format := range formats {
+		if _, err := NewAccessLog(nil, format); err == nil {
+			t.Errorf("expected error for format %q", format)
+		}
+	}
+}
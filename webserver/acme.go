@@ -0,0 +1,62 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"crypto/tls"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DefaultACMEDirectory is the default ACME directory URL, Let's Encrypt.
+const DefaultACMEDirectory = autocert.DefaultACMEDirectory
+
+// ACME reports whether the config specifies domains for ACME certificates.
+func (c ServerConfig) ACME() bool {
+	return len(c.ACMEDomains) > 0
+}
+
+// isACMEChallenge reports whether hello is from an ACME CA validating a
+// TLS-ALPN-01 challenge, which only offers the acme-tls/1 protocol. The
+// connection is closed after the handshake, as http.Server does not serve
+// the protocol.
+func isACMEChallenge(hello *tls.ClientHelloInfo) bool {
+	return len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto
+}
+
+// newACMEManager returns an autocert.Manager that obtains and renews
+// certificates for config.ACMEDomains from config.ACMEDirectory.
+// The TLS-ALPN-01 challenge is handled by the manager's GetCertificate, and
+// the HTTP-01 challenge by the manager's HTTPHandler on Config.RedirectAddr.
+func newACMEManager(config ServerConfig) *autocert.Manager {
+	directory := config.ACMEDirectory
+	if directory == "" {
+		directory = DefaultACMEDirectory
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.ACMEDomains...),
+		Email:      config.ACMEEmail,
+		Client:     &acme.Client{DirectoryURL: directory},
+	}
+
+	// without a cache, certificates are obtained again after each restart
+	if config.ACMECache != "" {
+		m.Cache = autocert.DirCache(config.ACMECache)
+	}
+
+	return m
+}
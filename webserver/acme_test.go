@@ -0,0 +1,143 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestACMERedirect(t *testing.T) {
+	config := DefaultServerConfig()
+	config.Addr = ":443"
+	config.ACMEDomains = []string{"example.com"}
+	config.RedirectAddr = ":80"
+
+	srv := NewServer(config, http.NotFoundHandler())
+
+	if !slices.Contains(srv.HTTPServer.TLSConfig.NextProtos, "acme-tls/1") {
+		t.Errorf("expected acme-tls/1 in NextProtos, got %v", srv.HTTPServer.TLSConfig.NextProtos)
+	}
+	if srv.redirect == nil {
+		t.Fatal("expected redirect server")
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		status   int
+		location string
+	}{
+		{
+			name:     "Redirect",
+			method:   http.MethodGet,
+			target:   "http://example.com/headers?a=1",
+			status:   http.StatusPermanentRedirect,
+			location: "https://example.com/headers?a=1",
+		},
+		{
+			name:     "Redirect with port",
+			method:   http.MethodHead,
+			target:   "http://example.com:80/",
+			status:   http.StatusPermanentRedirect,
+			location: "https://example.com/",
+		},
+		{
+			name:     "Post",
+			method:   http.MethodPost,
+			target:   "http://example.com/",
+			status:   http.StatusPermanentRedirect,
+			location: "https://example.com/",
+		},
+		{
+			name:   "Unknown challenge",
+			method: http.MethodGet,
+			target: "http://example.com/.well-known/acme-challenge/token",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			srv.redirect.Handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			if got := rr.Header().Get("Location"); got != tt.location {
+				t.Errorf("expected location %q, got %q", tt.location, got)
+			}
+		})
+	}
+}
+
+func TestACMEHTTPAddr(t *testing.T) {
+	config := DefaultServerConfig()
+	config.Addr = ":443"
+	config.ACMEDomains = []string{"example.com"}
+	config.ACMEHTTPAddr = ":80"
+
+	srv := NewServer(config, http.NotFoundHandler())
+
+	// the former name of RedirectAddr is still used
+	if srv.redirect == nil || srv.redirect.Addr != ":80" {
+		t.Fatalf("expected redirect server on :80, got %v", srv.redirect)
+	}
+}
+
+func TestACMEChallengeClientAuth(t *testing.T) {
+	config := DefaultServerConfig()
+	config.Addr = ":443"
+	config.ACMEDomains = []string{"example.com"}
+	config.ClientAuth = tls.RequireAnyClientCert
+
+	srv := NewServer(config, http.NotFoundHandler())
+	if err := srv.prepareTLS(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	getConfigForClient := srv.HTTPServer.TLSConfig.GetConfigForClient
+	if getConfigForClient == nil {
+		t.Fatal("expected GetConfigForClient")
+	}
+
+	tests := []struct {
+		name   string
+		protos []string
+		want   tls.ClientAuthType
+	}{
+		{name: "TLS-ALPN-01 challenge", protos: []string{"acme-tls/1"}, want: tls.NoClientCert},
+		{name: "HTTP/2", protos: []string{"h2", "http/1.1"}, want: tls.RequireAnyClientCert},
+		{name: "Challenge with other protocols", protos: []string{"h2", "acme-tls/1"}, want: tls.RequireAnyClientCert},
+		{name: "No protocols", want: tls.RequireAnyClientCert},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getConfigForClient(&tls.ClientHelloInfo{ServerName: "example.com", SupportedProtos: tt.protos})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ClientAuth != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got.ClientAuth)
+			}
+		})
+	}
+}
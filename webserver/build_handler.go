@@ -0,0 +1,70 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// buildDateTime is the executable modification date and time when the process
+// started, which identifies the build being served even if the executable has
+// since been replaced, e.g., before an Upgrade.
+var buildDateTime, buildDateTimeErr = ExecutableDateTime()
+
+// BuildPageData holds the data for BuildHandler.
+type BuildPageData struct {
+	Title string    `json:"-"`     // Title of the page.
+	Built time.Time `json:"built"` // Built is the executable modification time.
+	PID   int       `json:"pid"`   // PID is the process ID.
+}
+
+// Text returns the build date and time, and the process ID, each on a line.
+func (d BuildPageData) Text() string {
+	return fmt.Sprintf("%s\nPID: %d\n", d.Built.Format("2006-01-02 15:04:05"), d.PID)
+}
+
+// BuildHandler responds with the executable modification date and time when
+// the process started, and the process ID. The response is plain text by
+// default. See RenderPage.
+func (h *Handler) BuildHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	if buildDateTimeErr != nil {
+		http.Error(w, MsgTemplateError, http.StatusInternalServerError)
+		return
+	}
+
+	data := BuildPageData{
+		Title: "Build",
+		Built: buildDateTime,
+		PID:   os.Getpid(),
+	}
+
+	err := RenderPage(h.Templates(), w, r, TextPageName, data, FormatText)
+	if err != nil {
+		logger.Error("failed to RenderPage", "err", err)
+		return
+	}
+}
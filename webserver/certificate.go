@@ -0,0 +1,171 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CertificateLoader holds a TLS certificate and key pair loaded from files
+// that can be reloaded while the server is running.
+type CertificateLoader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	loaded   atomic.Pointer[time.Time]
+
+	mu     sync.Mutex   // mu protects stamps
+	stamps [2]fileStamp // stamps of certFile and keyFile when last loaded
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampFiles returns the fileStamp of each file in names.
+func stampFiles(names ...string) ([2]fileStamp, error) {
+	var stamps [2]fileStamp
+	for i, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return stamps, err
+		}
+		stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// NewCertificateLoader returns a CertificateLoader for the given files.
+// The files are not read until Reload is called.
+func NewCertificateLoader(certFile, keyFile string) *CertificateLoader {
+	return &CertificateLoader{certFile: certFile, keyFile: keyFile}
+}
+
+// Reload reads the certificate and key files. If an error occurs, the
+// previously loaded certificate, if any, continues to be used.
+func (l *CertificateLoader) Reload() error {
+	// record the files before loading, so a change during the load is
+	// detected by Watch, and a failed load is not retried until a change
+	stamps, err := stampFiles(l.certFile, l.keyFile)
+	if err == nil {
+		l.mu.Lock()
+		l.stamps = stamps
+		l.mu.Unlock()
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("CertificateLoader.Reload: %w", err)
+	}
+
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("CertificateLoader.Reload: %w", err)
+	}
+
+	now := time.Now()
+	l.cert.Store(&cert)
+	l.loaded.Store(&now)
+
+	slog.Info("loaded certificate",
+		slog.String("subject", cert.Leaf.Subject.String()),
+		slog.Time("notAfter", cert.Leaf.NotAfter),
+	)
+
+	return nil
+}
+
+// changed reports whether the certificate or key file has changed since
+// it was last loaded.
+func (l *CertificateLoader) changed() (bool, error) {
+	stamps, err := stampFiles(l.certFile, l.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return stamps != l.stamps, nil
+}
+
+// Watch checks the certificate and key files every interval and reloads them
+// when either has changed, until ctx is done. If a reload fails, the error is
+// logged and the previous certificate continues to be used.
+func (l *CertificateLoader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := l.changed()
+		if err != nil {
+			slog.Warn("failed to check certificate", "err", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		if err := l.Reload(); err != nil {
+			slog.Error("failed to reload certificate", "err", err)
+		}
+	}
+}
+
+// Leaf returns the parsed leaf of the current certificate, or nil if no
+// certificate is loaded.
+func (l *CertificateLoader) Leaf() *x509.Certificate {
+	cert := l.cert.Load()
+	if cert == nil {
+		return nil
+	}
+	return cert.Leaf
+}
+
+// Loaded returns the time the current certificate was loaded, or the zero
+// time if no certificate is loaded.
+func (l *CertificateLoader) Loaded() time.Time {
+	loaded := l.loaded.Load()
+	if loaded == nil {
+		return time.Time{}
+	}
+	return *loaded
+}
+
+// GetCertificate returns the current certificate.
+// It is intended to be used as tls.Config.GetCertificate.
+func (l *CertificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := l.cert.Load()
+	if cert == nil {
+		return nil, errors.New("GetCertificate: no certificate loaded")
+	}
+	return cert, nil
+}
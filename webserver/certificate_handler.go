@@ -0,0 +1,99 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CertificateHandler responds with the subject, issuer, names, and validity
+// of each certificate, and the time remaining until it expires.
+// It responds with 503 Service Unavailable if a certificate is not loaded.
+func (s CertificateSet) CertificateHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	for _, l := range s {
+		if l.Leaf() == nil {
+			logger.Error("certificate not loaded", "certFile", l.certFile)
+			http.Error(w, "certificate not loaded", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	for i, l := range s {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		writeCertificate(w, l)
+	}
+}
+
+// writeCertificate writes the details of the certificate of l to w.
+func writeCertificate(w io.Writer, l *CertificateLoader) {
+	leaf := l.Leaf()
+
+	writeX509Certificate(w, leaf)
+	fmt.Fprintf(w, "ExpiresInSeconds: %d\n", int64(time.Until(leaf.NotAfter).Seconds()))
+	fmt.Fprintf(w, "Loaded: %v\n", l.Loaded().UTC().Format(time.RFC3339))
+}
+
+// MetricsHandler responds with the expiry of each loaded certificate in the
+// Prometheus text format, as the gauge cert_not_after_seconds, the Unix time
+// of NotAfter, labeled by subject and serial number. Unlike
+// CertificateHandler, it does not require a token, e.g., for monitoring, as
+// certificates are sent to every TLS client.
+func (s CertificateSet) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP cert_not_after_seconds Expiry of the TLS certificate as a Unix time.")
+	fmt.Fprintln(w, "# TYPE cert_not_after_seconds gauge")
+	for _, l := range s {
+		leaf := l.Leaf()
+		if leaf == nil {
+			continue
+		}
+		fmt.Fprintf(w, "cert_not_after_seconds{subject=\"%s\",serial=\"%s\"} %d\n",
+			labelEscaper.Replace(leaf.Subject.String()), leaf.SerialNumber, leaf.NotAfter.Unix())
+	}
+}
+
+// MetricsHandler responds with the expiry of h.Certificates, if any. See
+// CertificateSet.MetricsHandler.
+func (h *Handler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	h.Certificates.MetricsHandler(w, r)
+}
+
+// labelEscaper escapes a Prometheus label value.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
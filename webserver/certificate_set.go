@@ -0,0 +1,84 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+)
+
+// CertificateFiles are the names of a certificate file and its key file.
+type CertificateFiles struct {
+	CertFile string // CertFile is the TLS certificate file.
+	KeyFile  string // KeyFile is the TLS private key file.
+}
+
+// CertificateSet holds several certificates, which are selected by the
+// server name indicated by the client (SNI).
+type CertificateSet []*CertificateLoader
+
+// NewCertificateSet returns a CertificateSet with a CertificateLoader for
+// each of files. The files are not read until Reload is called.
+func NewCertificateSet(files ...CertificateFiles) CertificateSet {
+	set := make(CertificateSet, 0, len(files))
+	for _, f := range files {
+		set = append(set, NewCertificateLoader(f.CertFile, f.KeyFile))
+	}
+	return set
+}
+
+// Reload reloads each certificate. If an error occurs, the previously loaded
+// certificate, if any, continues to be used, and the remaining certificates
+// are still reloaded.
+func (s CertificateSet) Reload() error {
+	var errs []error
+	for _, l := range s {
+		errs = append(errs, l.Reload())
+	}
+	return errors.Join(errs...)
+}
+
+// Watch watches each certificate for changes until ctx is done.
+// See CertificateLoader.Watch.
+func (s CertificateSet) Watch(ctx context.Context, interval time.Duration) {
+	for _, l := range s {
+		go l.Watch(ctx, interval)
+	}
+}
+
+// GetCertificate returns the first certificate that supports the client
+// hello, e.g., whose names match the server name, or otherwise the first
+// loaded certificate. It is intended to be used as tls.Config.GetCertificate.
+func (s CertificateSet) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	var fallback *tls.Certificate
+	for _, l := range s {
+		cert := l.cert.Load()
+		if cert == nil {
+			continue
+		}
+		if hello != nil && hello.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+		if fallback == nil {
+			fallback = cert
+		}
+	}
+
+	if fallback == nil {
+		return nil, errors.New("GetCertificate: no certificate loaded")
+	}
+	return fallback, nil
+}
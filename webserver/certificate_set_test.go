@@ -0,0 +1,73 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+)
+
+func TestCertificateSetGetCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	var files []CertificateFiles
+	for _, name := range []string{"default.example.com", "diag.example.com", "echo.example.com"} {
+		certFile, keyFile := writeTestCertificate(t, dir, name)
+		files = append(files, CertificateFiles{CertFile: certFile, KeyFile: keyFile})
+	}
+
+	set := NewCertificateSet(files...)
+
+	if _, err := set.GetCertificate(&tls.ClientHelloInfo{}); err == nil {
+		t.Error("expected error before Reload")
+	}
+
+	if err := set.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		serverName string
+		expected   string
+	}{
+		{serverName: "diag.example.com", expected: "diag.example.com"},
+		{serverName: "echo.example.com", expected: "echo.example.com"},
+		{serverName: "other.example.com", expected: "default.example.com"},
+		{serverName: "", expected: "default.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.serverName, func(t *testing.T) {
+			hello := &tls.ClientHelloInfo{
+				ServerName:        tt.serverName,
+				SupportedVersions: []uint16{tls.VersionTLS13},
+				SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+			}
+
+			cert, err := set.GetCertificate(hello)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leaf.Subject.CommonName != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, leaf.Subject.CommonName)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate and key for the
+// common name to dir and returns the file names.
+func writeTestCertificate(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, commonName+".crt")
+	keyFile = filepath.Join(dir, commonName+".key")
+
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+// commonName returns the subject common name of the loader's certificate.
+func commonName(t *testing.T, l *CertificateLoader) string {
+	t.Helper()
+
+	cert, err := l.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateLoaderReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "server")
+
+	l := NewCertificateLoader(certFile, keyFile)
+
+	if _, err := l.GetCertificate(nil); err == nil {
+		t.Error("expected error before Reload")
+	}
+
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := commonName(t, l); got != "server" {
+		t.Errorf("expected common name 'server', got '%s'", got)
+	}
+
+	// replace the files with a new certificate
+	newCert, newKey := writeTestCertificate(t, dir, "renewed")
+	if err := os.Rename(newCert, certFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(newKey, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := commonName(t, l); got != "renewed" {
+		t.Errorf("expected common name 'renewed', got '%s'", got)
+	}
+
+	// a failed reload keeps the previous certificate
+	if err := os.WriteFile(certFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reload(); err == nil {
+		t.Error("expected error for invalid certificate")
+	}
+	if got := commonName(t, l); got != "renewed" {
+		t.Errorf("expected common name 'renewed', got '%s'", got)
+	}
+}
+
+func TestCertificateLoaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "server")
+
+	l := NewCertificateLoader(certFile, keyFile)
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Watch(ctx, 10*time.Millisecond)
+
+	// waitFor waits for the loader to have a certificate for name
+	waitFor := func(name string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for commonName(t, l) != name {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected common name '%s', got '%s'", name, commonName(t, l))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// a partially written certificate keeps the previous certificate
+	if err := os.WriteFile(certFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	waitFor("server")
+
+	// replace the files with a new certificate
+	newCert, newKey := writeTestCertificate(t, dir, "renewed")
+	if err := os.Rename(newKey, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(newCert, certFile); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("renewed")
+}
+
+func TestCertificateHandler(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "server")
+
+	l := NewCertificateLoader(certFile, keyFile)
+
+	tests := []struct {
+		name     string
+		reload   bool
+		method   string
+		status   int
+		expected []string
+	}{
+		{
+			name:   "Not loaded",
+			method: http.MethodGet,
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "Loaded",
+			reload: true,
+			method: http.MethodGet,
+			status: http.StatusOK,
+			expected: []string{
+				"Subject: CN=server\n",
+				"DNSNames: server\n",
+				"ExpiresInSeconds: 3",
+			},
+		},
+		{
+			name:   "Invalid method",
+			method: http.MethodPost,
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.reload {
+				if err := l.Reload(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rr := httptest.NewRecorder()
+			CertificateSet{l}.CertificateHandler(rr, httptest.NewRequest(tt.method, "/admin/certificate", nil))
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("expected %q in body:\n%s", want, rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCertificateMetricsHandler(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "server")
+	otherCertFile, otherKeyFile := writeTestCertificate(t, dir, "other")
+
+	loaded := NewCertificateLoader(certFile, keyFile)
+	if err := loaded.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	notLoaded := NewCertificateLoader(otherCertFile, otherKeyFile)
+
+	tests := []struct {
+		name     string
+		method   string
+		status   int
+		expected []string
+	}{
+		{
+			name:   "Metrics",
+			method: http.MethodGet,
+			status: http.StatusOK,
+			expected: []string{
+				"# TYPE cert_not_after_seconds gauge\n",
+				fmt.Sprintf("cert_not_after_seconds{subject=\"CN=server\",serial=\"%s\"} %d\n",
+					loaded.Leaf().SerialNumber, loaded.Leaf().NotAfter.Unix()),
+			},
+		},
+		{
+			name:   "Invalid method",
+			method: http.MethodPost,
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			CertificateSet{loaded, notLoaded}.MetricsHandler(rr, httptest.NewRequest(tt.method, "/metrics", nil))
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("expected %q in body:\n%s", want, rr.Body.String())
+				}
+			}
+			if strings.Contains(rr.Body.String(), "CN=other") {
+				t.Errorf("expected no metric for a certificate that is not loaded:\n%s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ClientAuthTypes maps client authentication mode names to values. The
+// request and require modes accept any client certificate without verifying
+// its chain against the client CAs, so only verify authenticates clients.
+var ClientAuthTypes = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.RequestClientCert,
+	"require": tls.RequireAnyClientCert,
+	"verify":  tls.RequireAndVerifyClientCert,
+}
+
+// ClientAuthNames returns a comma-separated string of the names in
+// ClientAuthTypes, in order of increasing strictness.
+func ClientAuthNames() string {
+	names := make([]string, 0, len(ClientAuthTypes))
+	for name := range ClientAuthTypes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return ClientAuthTypes[names[i]] < ClientAuthTypes[names[j]]
+	})
+	return strings.Join(names, ", ")
+}
+
+// ClientAuth returns the tls.ClientAuthType for the mode name, or
+// tls.NoClientCert if name is empty. The lookup is case insensitive.
+func ClientAuth(name string) (tls.ClientAuthType, error) {
+	if name == "" {
+		return tls.NoClientCert, nil
+	}
+
+	clientAuth, ok := ClientAuthTypes[strings.ToLower(name)]
+	if !ok {
+		return tls.NoClientCert, fmt.Errorf("invalid client auth: %s", name)
+	}
+
+	return clientAuth, nil
+}
+
+// LoadCertPool returns a pool with the PEM encoded certificates in the file.
+func LoadCertPool(name string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCertPool: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("LoadCertPool: no certificates in %s", name)
+	}
+
+	return pool, nil
+}
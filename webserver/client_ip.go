@@ -0,0 +1,327 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// DefaultClientIPHeaders are the headers used by ProxyConfig if Headers is
+// empty. Other supported headers are X-Real-IP, CF-Connecting-IP, and
+// True-Client-IP, which should only be added if a trusted proxy sets them.
+var DefaultClientIPHeaders = []string{"Forwarded", "X-Forwarded-For"}
+
+// ClientInfo holds the client details resolved for a request.
+type ClientInfo struct {
+	IP     string `json:"ip"`     // IP is the client IP address.
+	Scheme string `json:"scheme"` // Scheme is the scheme used by the client, "http" or "https".
+	Host   string `json:"host"`   // Host is the host requested by the client.
+	Source string `json:"source"` // Source is where IP was found, e.g., "RemoteAddr", "PROXY", or a header name.
+}
+
+// ProxyConfig configures how the client is resolved from requests that pass
+// through proxies or load balancers.
+type ProxyConfig struct {
+	// TrustedProxies are the addresses of proxies whose headers are used.
+	// Headers from other addresses are ignored.
+	TrustedProxies []netip.Prefix
+
+	// Headers are the headers, in order of preference, used to find the
+	// client IP. If empty, DefaultClientIPHeaders is used.
+	Headers []string
+}
+
+// ParseTrustedProxies parses CIDRs, e.g., "10.0.0.0/8", or single IP
+// addresses into prefixes for ProxyConfig.TrustedProxies.
+func ParseTrustedProxies(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %s", cidr)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", cidr)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// trusted reports whether addr is one of the trusted proxies.
+func (c ProxyConfig) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range c.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveClient returns the client details for r. If r is from a trusted
+// proxy, the headers are searched right-to-left for the first address that is
+// not a trusted proxy, which is the client IP. If that address is unknown or
+// obfuscated, e.g., "for=unknown", RemoteAddr is used.
+func (c ProxyConfig) ResolveClient(r *http.Request) ClientInfo {
+	info := ClientInfo{
+		IP:     hostOnly(r.RemoteAddr),
+		Scheme: "http",
+		Host:   r.Host,
+		Source: "RemoteAddr",
+	}
+	if r.TLS != nil {
+		info.Scheme = "https"
+	}
+
+	// RemoteAddr is from the PROXY protocol header, if any
+	if header, ok := ProxyHeaderFromContext(r.Context()); ok && header.Source != nil {
+		info.Source = "PROXY"
+	}
+
+	peer, err := netip.ParseAddr(info.IP)
+	if err != nil || !c.trusted(peer) {
+		return info
+	}
+
+	headers := c.Headers
+	if len(headers) == 0 {
+		headers = DefaultClientIPHeaders
+	}
+
+	for _, header := range headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		var (
+			ok  bool
+			err error
+		)
+		switch http.CanonicalHeaderKey(header) {
+		case "Forwarded":
+			ok, err = c.resolveForwarded(values, &info)
+		case "X-Forwarded-For":
+			ok, err = c.resolveXForwardedFor(values, r.Header, &info)
+		default:
+			// single address headers, e.g., X-Real-IP
+			if addr, valid := parseNode(values[len(values)-1]); valid {
+				info.IP = addr.String()
+				ok = true
+			}
+		}
+
+		if err != nil {
+			// the client is hidden behind an unknown hop, so use the
+			// address of the peer rather than another header
+			return info
+		}
+		if ok {
+			info.Source = http.CanonicalHeaderKey(header)
+			return info
+		}
+	}
+
+	return info
+}
+
+// resolveForwarded sets info from the RFC 7239 Forwarded header values.
+func (c ProxyConfig) resolveForwarded(values []string, info *ClientInfo) (bool, error) {
+	var elements []map[string]string
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			elements = append(elements, parseForwardedElement(element))
+		}
+	}
+
+	i, addr, err := c.rightmostUntrusted(len(elements), func(i int) string { return elements[i]["for"] })
+	if err != nil || i < 0 {
+		return false, err
+	}
+
+	info.IP = addr.String()
+	if proto := strings.ToLower(elements[i]["proto"]); proto == "http" || proto == "https" {
+		info.Scheme = proto
+	}
+	if host := elements[i]["host"]; host != "" {
+		info.Host = host
+	}
+
+	return true, nil
+}
+
+// resolveXForwardedFor sets info from the X-Forwarded-For header values and
+// the X-Forwarded-Proto and X-Forwarded-Host headers.
+func (c ProxyConfig) resolveXForwardedFor(values []string, header http.Header, info *ClientInfo) (bool, error) {
+	var nodes []string
+	for _, value := range values {
+		nodes = append(nodes, strings.Split(value, ",")...)
+	}
+
+	i, addr, err := c.rightmostUntrusted(len(nodes), func(i int) string { return nodes[i] })
+	if err != nil || i < 0 {
+		return false, err
+	}
+
+	info.IP = addr.String()
+	if proto := strings.ToLower(lastListValue(header.Values("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+		info.Scheme = proto
+	}
+	if host := lastListValue(header.Values("X-Forwarded-Host")); host != "" {
+		info.Host = host
+	}
+
+	return true, nil
+}
+
+// errUnknownClient indicates that a forwarded node could not be parsed,
+// e.g., "unknown" or an obfuscated identifier.
+var errUnknownClient = errors.New("unknown client")
+
+// rightmostUntrusted walks n nodes right-to-left and returns the index and
+// address of the first node that is not a trusted proxy. If all nodes are
+// trusted, the leftmost is returned. If n is zero, the index is -1.
+//
+// A node that cannot be parsed is not a trusted proxy, so it is the client
+// and errUnknownClient is returned.
+func (c ProxyConfig) rightmostUntrusted(n int, node func(i int) string) (int, netip.Addr, error) {
+	index, found := -1, netip.Addr{}
+
+	for i := n - 1; i >= 0; i-- {
+		addr, ok := parseNode(node(i))
+		if !ok {
+			return i, netip.Addr{}, errUnknownClient
+		}
+		index, found = i, addr
+		if !c.trusted(addr) {
+			break
+		}
+	}
+
+	return index, found, nil
+}
+
+// parseNode parses an address that may be quoted, bracketed, or include a
+// port, e.g., `"[2001:db8::1]:8080"` or `192.0.2.1:1234`.
+func parseNode(node string) (netip.Addr, bool) {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	node = strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+	addr, err := netip.ParseAddr(node)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// parseForwardedElement parses the semicolon separated pairs of an element
+// of the Forwarded header. The keys are lowercase and values are unquoted.
+func parseForwardedElement(element string) map[string]string {
+	pairs := make(map[string]string)
+
+	for _, pair := range splitQuoted(element, ';') {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = strings.ReplaceAll(value[1:len(value)-1], `\`, "")
+		}
+		pairs[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	return pairs
+}
+
+// splitQuoted splits s on sep, ignoring sep within quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// lastListValue returns the last comma separated value of the header values.
+func lastListValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	last := values[len(values)-1]
+	if i := strings.LastIndexByte(last, ','); i >= 0 {
+		last = last[i+1:]
+	}
+	return strings.TrimSpace(last)
+}
+
+// hostOnly returns the host of addr without the port, if any.
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// AddClientInfo is middleware that adds the ClientInfo resolved for each
+// request to the context.
+func (c ProxyConfig) AddClientInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientInfoKey, c.ResolveClient(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ClientInfoFromContext returns the client details from ctx and whether
+// they were present.
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	if ctx == nil {
+		return ClientInfo{}, false
+	}
+	info, ok := ctx.Value(clientInfoKey).(ClientInfo)
+	return info, ok
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveClient(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		clientIPs  []string
+		tls        bool
+		expected   ClientInfo
+	}{
+		{
+			name:       "Direct",
+			remoteAddr: "203.0.113.5:1234",
+			expected:   ClientInfo{IP: "203.0.113.5", Scheme: "http", Host: "example.com", Source: "RemoteAddr"},
+		},
+		{
+			name:       "Direct TLS",
+			remoteAddr: "203.0.113.5:1234",
+			tls:        true,
+			expected:   ClientInfo{IP: "203.0.113.5", Scheme: "https", Host: "example.com", Source: "RemoteAddr"},
+		},
+		{
+			name:       "Untrusted peer spoofing headers",
+			remoteAddr: "203.0.113.5:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"1.1.1.1"},
+				"X-Real-Ip":       {"1.1.1.1"},
+			},
+			clientIPs: []string{"X-Real-IP"},
+			expected:  ClientInfo{IP: "203.0.113.5", Scheme: "http", Host: "example.com", Source: "RemoteAddr"},
+		},
+		{
+			name:       "X-Forwarded-For",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For":   {"198.51.100.7, 10.1.1.1"},
+				"X-Forwarded-Proto": {"https"},
+				"X-Forwarded-Host":  {"public.example.com"},
+			},
+			expected: ClientInfo{IP: "198.51.100.7", Scheme: "https", Host: "public.example.com", Source: "X-Forwarded-For"},
+		},
+		{
+			name:       "X-Forwarded-For spoofed leftmost",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"1.1.1.1, 198.51.100.7", "10.1.1.1"},
+			},
+			expected: ClientInfo{IP: "198.51.100.7", Scheme: "http", Host: "example.com", Source: "X-Forwarded-For"},
+		},
+		{
+			name:       "X-Forwarded-For all trusted",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"10.2.2.2, 10.1.1.1"},
+			},
+			expected: ClientInfo{IP: "10.2.2.2", Scheme: "http", Host: "example.com", Source: "X-Forwarded-For"},
+		},
+		{
+			name:       "X-Forwarded-For invalid entry",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.7, garbage, 10.1.1.1"},
+			},
+			expected: ClientInfo{IP: "10.0.0.1", Scheme: "http", Host: "example.com", Source: "RemoteAddr"},
+		},
+		{
+			name:       "Forwarded",
+			remoteAddr: "[2001:db8::1]:443",
+			headers: map[string][]string{
+				"Forwarded": {`for="[2001:db8:cafe::17]:4711";proto=https;host=a.example, for=192.0.2.43;proto=http;host=b.example, for=192.0.2.10`},
+			},
+			expected: ClientInfo{IP: "192.0.2.43", Scheme: "http", Host: "b.example", Source: "Forwarded"},
+		},
+		{
+			name:       "Forwarded preferred over X-Forwarded-For",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Forwarded":       {`for="198.51.100.9:80";proto=https`},
+				"X-Forwarded-For": {"198.51.100.7"},
+			},
+			expected: ClientInfo{IP: "198.51.100.9", Scheme: "https", Host: "example.com", Source: "Forwarded"},
+		},
+		{
+			name:       "Forwarded unknown uses RemoteAddr",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Forwarded":       {"for=unknown"},
+				"X-Forwarded-For": {"198.51.100.7"},
+			},
+			expected: ClientInfo{IP: "10.0.0.1", Scheme: "http", Host: "example.com", Source: "RemoteAddr"},
+		},
+		{
+			name:       "Forwarded hidden behind trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Forwarded": {"for=198.51.100.7, for=_hidden, for=10.1.1.1"},
+			},
+			expected: ClientInfo{IP: "10.0.0.1", Scheme: "http", Host: "example.com", Source: "RemoteAddr"},
+		},
+		{
+			name:       "CF-Connecting-IP",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Cf-Connecting-Ip": {"2001:4860::8888"},
+				"X-Forwarded-For":  {"198.51.100.7"},
+			},
+			clientIPs: []string{"CF-Connecting-IP", "X-Forwarded-For"},
+			expected:  ClientInfo{IP: "2001:4860::8888", Scheme: "http", Host: "example.com", Source: "Cf-Connecting-Ip"},
+		},
+		{
+			name:       "True-Client-IP",
+			remoteAddr: "192.0.2.10:1234",
+			headers: map[string][]string{
+				"True-Client-Ip": {"198.51.100.8"},
+			},
+			clientIPs: []string{"True-Client-IP"},
+			expected:  ClientInfo{IP: "198.51.100.8", Scheme: "http", Host: "example.com", Source: "True-Client-Ip"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.headers {
+				req.Header[key] = values
+			}
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			config := ProxyConfig{TrustedProxies: trusted, Headers: tt.clientIPs}
+
+			got := config.ResolveClient(req)
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+
+			// the middleware stores the same result for RealIP
+			var realIP string
+			config.AddClientInfo(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				realIP = RealIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if realIP != tt.expected.IP {
+				t.Errorf("expected RealIP %q, got %q", tt.expected.IP, realIP)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0/8"} {
+		if _, err := ParseTrustedProxies([]string{cidr}); err == nil {
+			t.Errorf("expected error for %q", cidr)
+		}
+	}
+}
@@ -11,19 +11,17 @@ CONDITIONS OF ANY KIND, either express or implied.  See the License for the
 specific language governing permissions and limitations under the License.
 */
 
-package main
-
-import (
-	"html/template"
-)
-
-// Handler encapsulates the behavior for processing HTTP requests.
-type Handler struct {
-	AppName string             // AppName is the name of the application using this handler.
-	Tmpl    *template.Template // Tmpl holds the parsed templates to be rendered.
-}
-
-// NewHandler returns a new Handler instance with the given application name and template.
-func NewHandler(appName string, tmpl *template.Template) *Handler {
-	return &Handler{AppName: appName, Tmpl: tmpl}
-}
+// Package webserver provides diagnostic HTTP handlers, middleware, and a
+// server with graceful shutdown that can be embedded in other applications.
+//
+// A minimal program serves the default routes with request IDs and logging:
+//
+//	tmpl, err := webserver.DefaultTemplates()
+//	if err != nil {
+//		// handle error
+//	}
+//	h := webserver.NewHandler("My App", tmpl)
+//	srv := webserver.NewServer(webserver.DefaultServerConfig(),
+//		webserver.AddRequestID(webserver.LogRequest(h.NewServeMux())))
+//	err = srv.Run(ctx)
+package webserver
@@ -0,0 +1,60 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"html/template"
+	"sync"
+	"sync/atomic"
+)
+
+// Handler encapsulates the behavior for processing HTTP requests.
+type Handler struct {
+	AppName string // AppName is the name of the application using this handler.
+
+	Certificates CertificateSet // Certificates are reported by MetricsHandler, if any.
+
+	// Tmpl is the initial value of the parsed templates to be rendered. It is
+	// read on the first call to Templates or SetTmpl, and writing it after
+	// that has no effect, so use SetTmpl to replace the templates.
+	Tmpl *template.Template
+
+	tmpl atomic.Pointer[template.Template] // tmpl holds the parsed templates to be rendered.
+	seed sync.Once                         // seed stores Tmpl in tmpl on first use.
+}
+
+// NewHandler returns a new Handler instance with the given application name and template.
+func NewHandler(appName string, tmpl *template.Template) *Handler {
+	return &Handler{AppName: appName, Tmpl: tmpl}
+}
+
+// seedTemplates stores Tmpl as the templates to be rendered on first use.
+func (h *Handler) seedTemplates() {
+	h.seed.Do(func() { h.tmpl.Store(h.Tmpl) })
+}
+
+// Templates returns the parsed templates to be rendered, which are Tmpl
+// until replaced by SetTmpl.
+func (h *Handler) Templates() *template.Template {
+	h.seedTemplates()
+	return h.tmpl.Load()
+}
+
+// SetTmpl atomically replaces the templates to be rendered, which allows
+// templates to be reloaded while requests are being served. Tmpl is not
+// modified, since it may be read concurrently.
+func (h *Handler) SetTmpl(tmpl *template.Template) {
+	h.seedTemplates()
+	h.tmpl.Store(tmpl)
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestHandlerTemplates(t *testing.T) {
+	initial := template.Must(template.New("initial").Parse("initial"))
+	reloaded := template.Must(template.New("reloaded").Parse("reloaded"))
+
+	h := &Handler{AppName: "test", Tmpl: initial}
+	if got := h.Templates(); got != initial {
+		t.Errorf("expected Tmpl, got %v", got.Name())
+	}
+
+	h.SetTmpl(reloaded)
+	if got := h.Templates(); got != reloaded {
+		t.Errorf("expected reloaded templates, got %v", got.Name())
+	}
+
+	// Tmpl is only the initial value
+	h.Tmpl = template.Must(template.New("ignored").Parse("ignored"))
+	if got := h.Templates(); got != reloaded {
+		t.Errorf("expected reloaded templates after setting Tmpl, got %v", got.Name())
+	}
+}
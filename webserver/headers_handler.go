@@ -11,11 +11,13 @@ CONDITIONS OF ANY KIND, either express or implied.  See the License for the
 specific language governing permissions and limitations under the License.
 */
 
-package main
+package webserver
 
 import (
+	"fmt"
 	"net/http"
 	"sort"
+	"strings"
 )
 
 // HeadersPageName is the name of the HTTP template to execute.
@@ -23,14 +25,30 @@ const HeadersPageName = "headers.html"
 
 // HeaderInfo contains individual header details.
 type HeaderInfo struct {
-	Key   string
-	Value []string
+	Key   string   `json:"key"`
+	Value []string `json:"value"`
 }
 
 // HeadersPageData holds the data passed to the HTML template.
 type HeadersPageData struct {
-	Title   string       // Title of the page.
-	Headers []HeaderInfo // Sorted list of the request headers.
+	Title   string       `json:"-"`       // Title of the page.
+	Headers []HeaderInfo `json:"headers"` // Sorted list of the request headers.
+}
+
+// Text returns each header value on a line.
+func (d HeadersPageData) Text() string {
+	var b strings.Builder
+	writeHeaderInfo(&b, d.Headers)
+	return b.String()
+}
+
+// writeHeaderInfo writes each header value on a line to b.
+func writeHeaderInfo(b *strings.Builder, headers []HeaderInfo) {
+	for _, header := range headers {
+		for _, value := range header.Value {
+			fmt.Fprintf(b, "%s: %v\n", header.Key, value)
+		}
+	}
 }
 
 // NewHeaderInfo initializes and returns a sorted array of HeaderInfo from httpHeader.
@@ -46,7 +64,8 @@ func NewHeaderInfo(httpHeader http.Header) []HeaderInfo {
 	return headerList
 }
 
-// HeadersHandler prints the headers of the request in sorted order.
+// HeadersHandler prints the headers of the request in sorted order, as HTML
+// by default. See RenderPage.
 func (h *Handler) HeadersHandler(w http.ResponseWriter, r *http.Request) {
 	// get the logger from the context, which include request information
 	logger := Logger(r.Context())
@@ -65,9 +84,9 @@ func (h *Handler) HeadersHandler(w http.ResponseWriter, r *http.Request) {
 		Headers: sortedHeaders,
 	}
 
-	err := RenderTemplate(h.Tmpl, w, HeadersPageName, data)
+	err := RenderPage(h.Templates(), w, r, HeadersPageName, data, FormatHTML)
 	if err != nil {
-		logger.Error("failed to RenderTemplate", "err", err)
+		logger.Error("failed to RenderPage", "err", err)
 		return
 	}
 }
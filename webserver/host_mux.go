@@ -0,0 +1,68 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HostMux is an http.Handler that dispatches each request to the handler
+// for its host, which allows virtual hosts with different routes.
+// The host is the resolved client host from ProxyConfig.AddClientInfo, if
+// present, otherwise the Host of the request.
+type HostMux struct {
+	hosts    map[string]http.Handler
+	fallback http.Handler
+}
+
+// NewHostMux returns a HostMux that dispatches requests for hosts without a
+// handler to fallback.
+func NewHostMux(fallback http.Handler) *HostMux {
+	return &HostMux{hosts: make(map[string]http.Handler), fallback: fallback}
+}
+
+// Handle registers the handler for host. The host may be a wildcard, such as
+// "*.example.com", which matches a single label, e.g., "www.example.com".
+// An exact host takes precedence over a wildcard.
+func (m *HostMux) Handle(host string, handler http.Handler) {
+	m.hosts[strings.ToLower(host)] = handler
+}
+
+// Handler returns the handler for host.
+func (m *HostMux) Handler(host string) http.Handler {
+	host = strings.TrimSuffix(strings.ToLower(hostOnly(host)), ".")
+
+	if h, ok := m.hosts[host]; ok {
+		return h
+	}
+
+	if _, parent, ok := strings.Cut(host, "."); ok {
+		if h, ok := m.hosts["*."+parent]; ok {
+			return h
+		}
+	}
+
+	return m.fallback
+}
+
+// ServeHTTP dispatches the request to the handler for its host.
+func (m *HostMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if client, ok := ClientInfoFromContext(r.Context()); ok && client.Host != "" {
+		host = client.Host
+	}
+
+	m.Handler(host).ServeHTTP(w, r)
+}
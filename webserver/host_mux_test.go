@@ -0,0 +1,70 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostMux(t *testing.T) {
+	// respond responds with body
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, body)
+		})
+	}
+
+	m := NewHostMux(respond("default"))
+	m.Handle("diag.example.com", respond("diag"))
+	m.Handle("*.example.com", respond("wildcard"))
+	m.Handle("Echo.Example.com", respond("echo"))
+
+	tests := []struct {
+		name       string
+		host       string
+		clientHost string
+		expected   string
+	}{
+		{name: "Exact", host: "diag.example.com", expected: "diag"},
+		{name: "Port", host: "diag.example.com:8443", expected: "diag"},
+		{name: "Case", host: "ECHO.example.com", expected: "echo"},
+		{name: "Trailing dot", host: "echo.example.com.", expected: "echo"},
+		{name: "Wildcard", host: "www.example.com", expected: "wildcard"},
+		{name: "Wildcard single label", host: "a.b.example.com", expected: "default"},
+		{name: "Other", host: "example.org", expected: "default"},
+		{name: "Client host", host: "10.0.0.1:8080", clientHost: "diag.example.com", expected: "diag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tt.host
+			if tt.clientHost != "" {
+				ctx := context.WithValue(r.Context(), clientInfoKey, ClientInfo{Host: tt.clientHost})
+				r = r.WithContext(ctx)
+			}
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, r)
+
+			if rr.Body.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HSTSConfig configures the Strict-Transport-Security header.
+type HSTSConfig struct {
+	MaxAge            time.Duration // MaxAge is how long browsers only use HTTPS, or disabled if zero.
+	IncludeSubDomains bool          // IncludeSubDomains applies the policy to all subdomains.
+	Preload           bool          // Preload requests inclusion in browser preload lists.
+}
+
+// Value returns the Strict-Transport-Security header value.
+func (c HSTSConfig) Value() string {
+	value := "max-age=" + strconv.FormatInt(int64(c.MaxAge/time.Second), 10)
+	if c.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if c.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// AddHSTS is middleware that adds the Strict-Transport-Security header to
+// responses to HTTPS requests, including those from a trusted proxy using
+// HTTPS, if c.MaxAge is positive. Browsers ignore the header over HTTP.
+func (c HSTSConfig) AddHSTS(next http.Handler) http.Handler {
+	if c.MaxAge <= 0 {
+		return next
+	}
+
+	value := c.Value()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secure := r.TLS != nil
+		if client, ok := ClientInfoFromContext(r.Context()); ok {
+			secure = client.Scheme == "https"
+		}
+		if secure {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAddHSTS(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   HSTSConfig
+		tls      bool
+		scheme   string
+		expected string
+	}{
+		{
+			name:     "HTTPS",
+			config:   HSTSConfig{MaxAge: 365 * 24 * time.Hour},
+			tls:      true,
+			expected: "max-age=31536000",
+		},
+		{
+			name:     "All options",
+			config:   HSTSConfig{MaxAge: time.Hour, IncludeSubDomains: true, Preload: true},
+			tls:      true,
+			expected: "max-age=3600; includeSubDomains; preload",
+		},
+		{
+			name:     "HTTP",
+			config:   HSTSConfig{MaxAge: time.Hour},
+			expected: "",
+		},
+		{
+			name:     "HTTPS proxy",
+			config:   HSTSConfig{MaxAge: time.Hour},
+			scheme:   "https",
+			expected: "max-age=3600",
+		},
+		{
+			name:     "Disabled",
+			config:   HSTSConfig{},
+			tls:      true,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tt.scheme != "" {
+				r = r.WithContext(context.WithValue(r.Context(), clientInfoKey, ClientInfo{Scheme: tt.scheme}))
+			}
+
+			rr := httptest.NewRecorder()
+			tt.config.AddHSTS(http.NotFoundHandler()).ServeHTTP(rr, r)
+
+			if got := rr.Header().Get("Strict-Transport-Security"); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Protocols reported by Protocol, which are the ALPN protocol IDs.
+const (
+	ProtocolHTTP1 = "http/1.1"
+	ProtocolHTTP2 = "h2"
+	ProtocolH2C   = "h2c"
+)
+
+// http2Server returns the HTTP/2 server with the settings of c.
+func (c ServerConfig) http2Server() *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams: c.HTTP2MaxConcurrentStreams,
+		MaxReadFrameSize:     c.HTTP2MaxReadFrameSize,
+		IdleTimeout:          c.HTTP2IdleTimeout,
+	}
+}
+
+// configureHTTP2 configures s to use the HTTP/2 settings of its config for
+// TLS connections, if TLS is configured, and to serve h2c, if enabled, on
+// connections without TLS.
+func (s *Server) configureHTTP2() error {
+	h2 := s.Config.http2Server()
+
+	if s.HTTPServer.TLSConfig != nil {
+		if err := http2.ConfigureServer(s.HTTPServer, h2); err != nil {
+			return err
+		}
+	}
+
+	if s.Config.H2C {
+		s.HTTPServer.Handler = h2c.NewHandler(s.HTTPServer.Handler, h2)
+	}
+
+	return nil
+}
+
+// Protocol returns the protocol of the connection for r, e.g., "h2" for
+// HTTP/2 over TLS, "h2c" for HTTP/2 without TLS, or "h3" for HTTP/3.
+func Protocol(r *http.Request) string {
+	switch {
+	case r.ProtoMajor == 3:
+		return ProtocolHTTP3
+	case r.ProtoMajor == 2 && r.TLS != nil:
+		return ProtocolHTTP2
+	case r.ProtoMajor == 2:
+		return ProtocolH2C
+	case r.ProtoAtLeast(1, 1):
+		return ProtocolHTTP1
+	}
+	return "http/1.0"
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// serveHTTP2Test serves RequestHandler using config on a listener with TLS
+// and one without TLS, and returns their addresses.
+func serveHTTP2Test(t *testing.T, config ServerConfig) (tlsAddr, addr string) {
+	t.Helper()
+
+	var listeners []Listener
+	for _, useTLS := range []bool{true, false} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners = append(listeners, Listener{Listener: ln, TLS: useTLS})
+	}
+
+	srv := NewServer(config, http.HandlerFunc(handler.RequestHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() { errChan <- srv.ServeListeners(ctx, listeners) }()
+	t.Cleanup(func() {
+		cancel()
+		<-errChan
+	})
+
+	return listeners[0].Addr().String(), listeners[1].Addr().String()
+}
+
+func TestServerHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "server")
+
+	rootCAs, err := LoadCertPool(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultServerConfig()
+	config.CertFile = certFile
+	config.KeyFile = keyFile
+	config.HTTP2MaxConcurrentStreams = 10
+	config.HTTP2MaxReadFrameSize = 1 << 14
+	config.HTTP2IdleTimeout = time.Minute
+
+	h2cConfig := config
+	h2cConfig.H2C = true
+
+	tlsAddr, addr := serveHTTP2Test(t, config)
+	_, h2cAddr := serveHTTP2Test(t, h2cConfig)
+
+	h1 := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, ServerName: "server"}}
+	h2 := &http.Transport{TLSClientConfig: h1.TLSClientConfig.Clone(), ForceAttemptHTTP2: true}
+	h2c := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+
+	tests := []struct {
+		name      string
+		url       string
+		transport http.RoundTripper
+		wantErr   bool
+		expected  string
+	}{
+		{name: "HTTP/1.1", url: "http://" + addr + "/request", transport: h1, expected: "Protocol: http/1.1\n"},
+		{name: "HTTP/1.1 over TLS", url: "https://" + tlsAddr + "/request", transport: h1, expected: "Protocol: http/1.1\n"},
+		{name: "HTTP/2 over TLS", url: "https://" + tlsAddr + "/request", transport: h2, expected: "Protocol: h2\n"},
+		{name: "h2c", url: "http://" + h2cAddr + "/request", transport: h2c, expected: "Protocol: h2c\n"},
+		{name: "h2c disabled", url: "http://" + addr + "/request", transport: h2c, wantErr: true},
+		{name: "HTTP/1.1 with h2c", url: "http://" + h2cAddr + "/request", transport: h1, expected: "Protocol: http/1.1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: tt.transport, Timeout: 5 * time.Second}
+			resp, err := client.Get(tt.url)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if !strings.HasPrefix(string(body), tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, body)
+			}
+		})
+	}
+
+	h1.CloseIdleConnections()
+	h2.CloseIdleConnections()
+	h2c.CloseIdleConnections()
+}
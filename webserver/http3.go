@@ -0,0 +1,108 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ProtocolHTTP3 is the protocol reported by Protocol for HTTP/3 over QUIC.
+const ProtocolHTTP3 = "h3"
+
+// DefaultAltSvcMaxAge is the number of seconds clients may remember the
+// Alt-Svc advertisement of HTTP/3.
+const DefaultAltSvcMaxAge = 86400
+
+// http3Port returns the port of HTTP3Addr, or an error if it is invalid.
+func (c ServerConfig) http3Port() (int, error) {
+	_, port, err := net.SplitHostPort(c.HTTP3Addr)
+	if err != nil {
+		return 0, err
+	}
+	return net.LookupPort("udp", port)
+}
+
+// AdvertiseHTTP3 returns a handler that adds an Alt-Svc header advertising
+// HTTP/3 on port to responses to requests over TLS, other than HTTP/3, then
+// calls next.
+func AdvertiseHTTP3(port int, next http.Handler) http.Handler {
+	value := fmt.Sprintf(`h3=":%d"; ma=%d`, port, DefaultAltSvcMaxAge)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && r.ProtoMajor < 3 {
+			w.Header().Set("Alt-Svc", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newHTTP3Server returns the HTTP/3 server for handler, which uses the TLS
+// configuration of the HTTP server when it serves.
+func newHTTP3Server(config ServerConfig, handler http.Handler) *http3.Server {
+	return &http3.Server{
+		Handler:     handler,
+		IdleTimeout: config.IdleTimeout,
+	}
+}
+
+// listenHTTP3 returns the inherited connection for HTTP/3, if any, or listens
+// on Config.HTTP3Addr.
+func (s *Server) listenHTTP3() (net.PacketConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.packetConn != nil {
+		return s.packetConn, nil
+	}
+
+	pc, err := net.ListenPacket("udp", s.Config.HTTP3Addr)
+	if err != nil {
+		return nil, err
+	}
+	s.packetConn = pc
+
+	return pc, nil
+}
+
+// shutdownHTTP3 gracefully shuts down the HTTP/3 server, if any, and closes
+// its connection. After an Upgrade, the new process reads from the shared
+// connection, so HTTP/3 connections are closed immediately instead, and
+// clients connect again.
+func (s *Server) shutdownHTTP3(ctx context.Context) error {
+	if s.http3 == nil {
+		return nil
+	}
+
+	var err error
+	if s.upgraded.Load() {
+		err = s.http3.Close()
+	} else {
+		err = s.http3.Shutdown(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.packetConn != nil {
+		err = errors.Join(err, s.packetConn.Close())
+		s.packetConn = nil
+	}
+
+	return err
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestAdvertiseHTTP3(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      bool
+		proto    int
+		expected string
+	}{
+		{name: "HTTPS", tls: true, proto: 2, expected: `h3=":8443"; ma=86400`},
+		{name: "HTTP", proto: 1},
+		{name: "HTTP/3", tls: true, proto: 3},
+	}
+
+	h := AdvertiseHTTP3(8443, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.ProtoMajor = tt.proto
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Alt-Svc"); got != tt.expected {
+				t.Errorf("expected Alt-Svc %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestServerHTTP3(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "server")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+
+	config := DefaultServerConfig()
+	config.Addr = ""
+	config.CertFile = certFile
+	config.KeyFile = keyFile
+	config.HTTP3Addr = pc.LocalAddr().String()
+	srv := NewServer(config, handler.NewServeMux())
+
+	// use the inherited listener and packet connection, as after an upgrade
+	srv.Inherit([]InheritedListener{
+		{Listener: ln, Name: "https"},
+		{PacketConn: pc, Name: ProtocolHTTP3},
+	})
+	listeners, err := srv.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() { errChan <- srv.ServeListeners(ctx, listeners) }()
+
+	rootCAs, err := LoadCertPool(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig := &tls.Config{RootCAs: rootCAs, ServerName: "server"}
+
+	h2 := &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true}
+	h3 := &http3.Transport{TLSClientConfig: tlsConfig}
+
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		addr      string
+		path      string
+		altSvc    string
+		expected  []string
+	}{
+		{
+			name:      "Request over HTTP/2",
+			transport: h2,
+			addr:      ln.Addr().String(),
+			path:      "/request",
+			altSvc:    `h3=":` + strconv.Itoa(port) + `"; ma=86400`,
+			expected:  []string{"Protocol: h2\n"},
+		},
+		{
+			name:      "Request over HTTP/3",
+			transport: h3,
+			addr:      pc.LocalAddr().String(),
+			path:      "/request",
+			expected:  []string{"Protocol: h3\n", "GET /request HTTP/3.0"},
+		},
+		{
+			name:      "TLS over HTTP/3",
+			transport: h3,
+			addr:      pc.LocalAddr().String(),
+			path:      "/tls",
+			expected:  []string{"TLS: true\n", "Protocol: h3\n", "Version: TLS 1.3\n", "NegotiatedProtocol: h3\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: tt.transport, Timeout: 5 * time.Second}
+			resp, err := client.Get("https://" + tt.addr + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if got := resp.Header.Get("Alt-Svc"); got != tt.altSvc {
+				t.Errorf("expected Alt-Svc %q, got %q", tt.altSvc, got)
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("expected %q in body:\n%s", want, body)
+				}
+			}
+		})
+	}
+
+	h2.CloseIdleConnections()
+	h3.Close()
+
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
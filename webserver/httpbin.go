@@ -0,0 +1,292 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// HTTPBinMaxBody is the maximum size of a request body read by the
+// httpbin-compatible routes.
+const HTTPBinMaxBody = 10 << 20
+
+// HTTPBinGet is the response of /get, which matches httpbin.
+type HTTPBinGet struct {
+	Args    map[string]any    `json:"args"`    // Args are the query parameters.
+	Headers map[string]string `json:"headers"` // Headers of the request, including Host.
+	Origin  string            `json:"origin"`  // Origin is the client IP.
+	URL     string            `json:"url"`     // URL requested by the client.
+}
+
+// HTTPBinBody is the response of /post, /put, /patch, and /delete, which
+// matches httpbin.
+type HTTPBinBody struct {
+	HTTPBinGet
+	Data  string            `json:"data"`  // Data is the body, unless it is a form.
+	Files map[string]string `json:"files"` // Files uploaded in a multipart form.
+	Form  map[string]any    `json:"form"`  // Form values of the body.
+	JSON  any               `json:"json"`  // JSON is the body, if it is valid JSON.
+}
+
+// HTTPBinAnything is the response of /anything, which matches httpbin.
+type HTTPBinAnything struct {
+	HTTPBinBody
+	Method string `json:"method"` // Method of the request.
+}
+
+// requestClient returns the client of r from ProxyConfig.AddClientInfo, if
+// used, otherwise from the connection.
+func requestClient(r *http.Request) ClientInfo {
+	if client, ok := ClientInfoFromContext(r.Context()); ok {
+		return client
+	}
+	return ProxyConfig{}.ResolveClient(r)
+}
+
+// newHTTPBinGet returns the HTTPBinGet for r.
+func newHTTPBinGet(r *http.Request) HTTPBinGet {
+	client := requestClient(r)
+
+	headers := map[string]string{"Host": r.Host}
+	for key, values := range r.Header {
+		headers[key] = strings.Join(values, ",")
+	}
+
+	u := url.URL{Scheme: client.Scheme, Host: client.Host}
+	return HTTPBinGet{
+		Args:    httpBinValues(r.URL.Query()),
+		Headers: headers,
+		Origin:  client.IP,
+		URL:     u.String() + r.URL.RequestURI(),
+	}
+}
+
+// newHTTPBinBody returns the HTTPBinBody for r, reading at most
+// HTTPBinMaxBody bytes of the body.
+func newHTTPBinBody(w http.ResponseWriter, r *http.Request) (HTTPBinBody, error) {
+	data := HTTPBinBody{
+		HTTPBinGet: newHTTPBinGet(r),
+		Files:      map[string]string{},
+		Form:       map[string]any{},
+	}
+
+	if r.Body == nil {
+		return data, nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, HTTPBinMaxBody))
+	if err != nil {
+		return data, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return data, err
+		}
+		data.Form = httpBinValues(form)
+	case "multipart/form-data":
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := r.ParseMultipartForm(HTTPBinMaxBody); err != nil {
+			return data, err
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		data.Form = httpBinValues(r.MultipartForm.Value)
+		for name, headers := range r.MultipartForm.File {
+			f, err := headers[0].Open()
+			if err != nil {
+				return data, err
+			}
+			b, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return data, err
+			}
+			data.Files[name] = httpBinString(b)
+		}
+	default:
+		data.Data = httpBinString(body)
+		if json.Valid(body) {
+			json.Unmarshal(body, &data.JSON)
+		}
+	}
+
+	return data, nil
+}
+
+// httpBinValues returns values as httpbin does, a string for a single value
+// and a list for multiple values.
+func httpBinValues(values map[string][]string) map[string]any {
+	m := make(map[string]any, len(values))
+	for key, v := range values {
+		if len(v) == 1 {
+			m[key] = v[0]
+		} else {
+			m[key] = v
+		}
+	}
+	return m
+}
+
+// httpBinString returns b as a string if it is valid UTF-8, otherwise as a
+// base64 data URL, as httpbin does.
+func httpBinString(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(b)
+}
+
+// writeHTTPBinError responds with the error reading the body of the request.
+func writeHTTPBinError(w http.ResponseWriter, err error) {
+	if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// HTTPBinGetHandler responds with the query parameters, headers, origin,
+// and URL of the request, as httpbin /get does.
+func (h *Handler) HTTPBinGetHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	if err := RenderJSON(w, newHTTPBinGet(r)); err != nil {
+		logger.Error("failed to RenderJSON", "err", err)
+	}
+}
+
+// HTTPBinBodyHandler returns a handler for method that responds with the
+// query parameters, body, form, files, headers, origin, and URL of the
+// request, as httpbin /post, /put, /patch, and /delete do.
+func (h *Handler) HTTPBinBodyHandler(method string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := Logger(r.Context())
+
+		if !ValidMethod(w, r, method) {
+			logger.Error("invalid method")
+			return
+		}
+
+		// try and force client not to cache content
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+		data, err := newHTTPBinBody(w, r)
+		if err != nil {
+			logger.Error("failed to read body", "err", err)
+			writeHTTPBinError(w, err)
+			return
+		}
+
+		if err := RenderJSON(w, data); err != nil {
+			logger.Error("failed to RenderJSON", "err", err)
+		}
+	}
+}
+
+// HTTPBinAnythingHandler responds to any method with the request details of
+// HTTPBinBodyHandler and the method, as httpbin /anything does.
+func (h *Handler) HTTPBinAnythingHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	data, err := newHTTPBinBody(w, r)
+	if err != nil {
+		logger.Error("failed to read body", "err", err)
+		writeHTTPBinError(w, err)
+		return
+	}
+
+	err = RenderJSON(w, HTTPBinAnything{HTTPBinBody: data, Method: r.Method})
+	if err != nil {
+		logger.Error("failed to RenderJSON", "err", err)
+	}
+}
+
+// HTTPBinIPHandler responds with the client IP, as httpbin /ip does.
+func (h *Handler) HTTPBinIPHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	data := map[string]string{"origin": newHTTPBinGet(r).Origin}
+	if err := RenderJSON(w, data); err != nil {
+		logger.Error("failed to RenderJSON", "err", err)
+	}
+}
+
+// HTTPBinUserAgentHandler responds with the User-Agent header, as httpbin
+// /user-agent does.
+func (h *Handler) HTTPBinUserAgentHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	data := map[string]string{"user-agent": r.UserAgent()}
+	if err := RenderJSON(w, data); err != nil {
+		logger.Error("failed to RenderJSON", "err", err)
+	}
+}
+
+// HTTPBinUUIDHandler responds with a random UUIDv4, as httpbin /uuid does.
+func (h *Handler) HTTPBinUUIDHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	data := map[string]string{"uuid": UUIDv4Generator{}.NewRequestID()}
+	if err := RenderJSON(w, data); err != nil {
+		logger.Error("failed to RenderJSON", "err", err)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// multipartBody returns a multipart form with a field and a file.
+func multipartBody(t *testing.T) (string, string) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("name", "value"); err != nil {
+		t.Fatal(err)
+	}
+	fw, err := mw.CreateFormFile("upload", "upload.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("file contents"))
+	mw.Close()
+
+	return body.String(), mw.FormDataContentType()
+}
+
+func TestHTTPBin(t *testing.T) {
+	multipartData, multipartType := multipartBody(t)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		contentType  string
+		body         string
+		expectedCode int
+		expected     map[string]any
+	}{
+		{
+			name:         "Get",
+			method:       http.MethodGet,
+			target:       "/get?a=1&a=2&b=3",
+			expectedCode: http.StatusOK,
+			expected: map[string]any{
+				"args":   map[string]any{"a": []any{"1", "2"}, "b": "3"},
+				"origin": "192.0.2.1",
+				"url":    "http://example.com/get?a=1&a=2&b=3",
+			},
+		},
+		{
+			name:         "Get invalid method",
+			method:       http.MethodPost,
+			target:       "/get",
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "Post JSON",
+			method:       http.MethodPost,
+			target:       "/post",
+			contentType:  "application/json",
+			body:         `{"key": "value"}`,
+			expectedCode: http.StatusOK,
+			expected: map[string]any{
+				"args":  map[string]any{},
+				"data":  `{"key": "value"}`,
+				"files": map[string]any{},
+				"form":  map[string]any{},
+				"json":  map[string]any{"key": "value"},
+			},
+		},
+		{
+			name:         "Post form",
+			method:       http.MethodPost,
+			target:       "/post",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         "a=1&b=2&b=3",
+			expectedCode: http.StatusOK,
+			expected: map[string]any{
+				"data": "",
+				"form": map[string]any{"a": "1", "b": []any{"2", "3"}},
+				"json": nil,
+			},
+		},
+		{
+			name:         "Post multipart",
+			method:       http.MethodPost,
+			target:       "/post",
+			contentType:  multipartType,
+			body:         multipartData,
+			expectedCode: http.StatusOK,
+			expected: map[string]any{
+				"files": map[string]any{"upload": "file contents"},
+				"form":  map[string]any{"name": "value"},
+			},
+		},
+		{
+			name:         "Put binary",
+			method:       http.MethodPut,
+			target:       "/put",
+			contentType:  "application/octet-stream",
+			body:         "\xff\xfe",
+			expectedCode: http.StatusOK,
+			expected: map[string]any{
+				"data": "data:application/octet-stream;base64,//4=",
+			},
+		},
+		{
+			name:         "Patch text",
+			method:       http.MethodPatch,
+			target:       "/patch",
+			contentType:  "text/plain",
+			body:         "text",
+			expectedCode: http.StatusOK,
+			expected:     map[string]any{"data": "text", "json": nil},
+		},
+		{
+			name:         "Delete",
+			method:       http.MethodDelete,
+			target:       "/delete?id=1",
+			expectedCode: http.StatusOK,
+			expected:     map[string]any{"args": map[string]any{"id": "1"}, "data": ""},
+		},
+		{
+			name:         "Delete invalid method",
+			method:       http.MethodGet,
+			target:       "/delete",
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "Anything",
+			method:       http.MethodPatch,
+			target:       "/anything/path",
+			body:         "[1, 2]",
+			expectedCode: http.StatusOK,
+			expected: map[string]any{
+				"method": http.MethodPatch,
+				"url":    "http://example.com/anything/path",
+				"json":   []any{1.0, 2.0},
+			},
+		},
+		{
+			name:         "IP",
+			method:       http.MethodGet,
+			target:       "/ip",
+			expectedCode: http.StatusOK,
+			expected:     map[string]any{"origin": "192.0.2.1"},
+		},
+		{
+			name:         "User-Agent",
+			method:       http.MethodGet,
+			target:       "/user-agent",
+			expectedCode: http.StatusOK,
+			expected:     map[string]any{"user-agent": "test-agent"},
+		},
+	}
+
+	mux := handler.NewServeMux()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("User-Agent", "test-agent")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("expected status code %d, got %d", tt.expectedCode, rr.Code)
+			}
+			if tt.expected == nil {
+				return
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("expected JSON, got %q: %v", rr.Body.String(), err)
+			}
+			for key, expected := range tt.expected {
+				if !reflect.DeepEqual(got[key], expected) {
+					t.Errorf("expected %s %#v, got %#v", key, expected, got[key])
+				}
+			}
+
+			// the headers include the Host, as httpbin does
+			if headers, ok := got["headers"].(map[string]any); ok && headers["Host"] != "example.com" {
+				t.Errorf("expected Host header, got %v", headers)
+			}
+		})
+	}
+}
+
+func TestHTTPBinUUID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/uuid", nil)
+	rr := httptest.NewRecorder()
+	handler.HTTPBinUUIDHandler(rr, req)
+
+	var got struct {
+		UUID string `json:"uuid"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("expected JSON, got %q: %v", rr.Body.String(), err)
+	}
+
+	uuidv4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuidv4.MatchString(got.UUID) {
+		t.Errorf("expected UUIDv4, got %q", got.UUID)
+	}
+}
+
+func TestHTTPBinMaxBody(t *testing.T) {
+	body := strings.NewReader(strings.Repeat("x", HTTPBinMaxBody+1))
+	req := httptest.NewRequest(http.MethodPost, "/post", body)
+	rr := httptest.NewRecorder()
+	handler.NewServeMux().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status code %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ListenerConfig configures an additional listener.
+type ListenerConfig struct {
+	Network string      // Network is "tcp" or "unix".
+	Addr    string      // Addr is the [host]:port, or the path of a Unix domain socket.
+	TLS     bool        // TLS serves HTTPS on the listener.
+	Mode    fs.FileMode // Mode is the permissions of a Unix domain socket, or the default if zero.
+	User    string      // User is the owner, as a name or ID, of a Unix domain socket, or unchanged if empty.
+	Group   string      // Group is the group, as a name or ID, of a Unix domain socket, or unchanged if empty.
+}
+
+// ParseListenerConfig parses a listener spec, which is a [host]:port, or a
+// URL such as "tcp://127.0.0.1:8080", "tcp://:8443?tls=true", or
+// "unix:///run/go-webserver.sock?mode=0660&user=www&group=www".
+func ParseListenerConfig(spec string) (ListenerConfig, error) {
+	u, err := url.Parse(spec)
+	if err != nil || u.Scheme == "" || u.Opaque != "" {
+		// not a URL, so a [host]:port
+		if _, _, err := net.SplitHostPort(spec); err != nil {
+			return ListenerConfig{}, fmt.Errorf("invalid listener %q: %w", spec, err)
+		}
+		return ListenerConfig{Network: "tcp", Addr: spec}, nil
+	}
+
+	var c ListenerConfig
+	switch u.Scheme {
+	case "tcp":
+		c = ListenerConfig{Network: "tcp", Addr: u.Host}
+	case "unix":
+		c = ListenerConfig{Network: "unix", Addr: u.Host + u.Path}
+		if c.Addr == "" {
+			return ListenerConfig{}, fmt.Errorf("invalid listener %q: missing socket path", spec)
+		}
+	default:
+		return ListenerConfig{}, fmt.Errorf("invalid listener %q: unsupported network %q", spec, u.Scheme)
+	}
+
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+		switch {
+		case key == "tls":
+			c.TLS, err = strconv.ParseBool(value)
+		case key == "mode" && c.Network == "unix":
+			var mode uint64
+			mode, err = strconv.ParseUint(value, 8, 32)
+			c.Mode = fs.FileMode(mode) & fs.ModePerm
+		case key == "user" && c.Network == "unix":
+			c.User = value
+		case key == "group" && c.Network == "unix":
+			c.Group = value
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return ListenerConfig{}, fmt.Errorf("invalid listener %q: %s: %w", spec, key, err)
+		}
+	}
+
+	return c, nil
+}
+
+// String returns the listener spec for c.
+func (c ListenerConfig) String() string {
+	u := url.URL{Scheme: c.Network, Host: c.Addr}
+	if c.Network == "unix" {
+		u = url.URL{Scheme: c.Network, Path: c.Addr}
+	}
+
+	q := url.Values{}
+	if c.TLS {
+		q.Set("tls", "true")
+	}
+	if c.Mode != 0 {
+		q.Set("mode", fmt.Sprintf("%04o", uint32(c.Mode)))
+	}
+	if c.User != "" {
+		q.Set("user", c.User)
+	}
+	if c.Group != "" {
+		q.Set("group", c.Group)
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// Listen returns a listener for c. A stale Unix domain socket is removed,
+// but a socket that accepts connections is an error. A socket with a Mode,
+// User, or Group is only accessible by the owner until they are set. The
+// socket is removed when the listener is closed.
+func (c ListenerConfig) Listen() (net.Listener, error) {
+	if c.Network != "unix" {
+		return net.Listen(c.Network, c.Addr)
+	}
+
+	if err := removeStaleSocket(c.Addr); err != nil {
+		return nil, err
+	}
+
+	ln, err := c.listenUnix()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.setOwnership(); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("%s: %w", c.Addr, err)
+	}
+
+	return ln, nil
+}
+
+// ownerOnlyUmask is the umask that creates a socket only accessible by the
+// owner.
+const ownerOnlyUmask = 0o077
+
+// umaskMu serializes changes to the process umask.
+var umaskMu sync.Mutex
+
+// listenUnix listens on the Unix domain socket c.Addr. If c sets the
+// permissions or ownership of the socket, it is created with ownerOnlyUmask,
+// so other users cannot connect before setOwnership.
+func (c ListenerConfig) listenUnix() (net.Listener, error) {
+	if c.Mode == 0 && c.User == "" && c.Group == "" {
+		return net.Listen("unix", c.Addr)
+	}
+
+	// the umask applies to the process, so restore it immediately
+	umaskMu.Lock()
+	umask := syscall.Umask(ownerOnlyUmask)
+	ln, err := net.Listen("unix", c.Addr)
+	syscall.Umask(umask)
+	umaskMu.Unlock()
+
+	return ln, err
+}
+
+// removeStaleSocket removes a socket at path left by a previous process that
+// did not exit cleanly. The socket is only removed if connections to it are
+// refused, so a socket in use by another process is left in place.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode().Type() != fs.ModeSocket {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s: %w", path, errSocketInUse)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return os.Remove(path)
+}
+
+// errSocketInUse indicates a Unix domain socket accepts connections.
+var errSocketInUse = errors.New("socket in use")
+
+// setOwnership sets the permissions, owner, and group of the socket.
+func (c ListenerConfig) setOwnership() error {
+	if c.Mode != 0 {
+		if err := os.Chmod(c.Addr, c.Mode); err != nil {
+			return err
+		}
+	}
+
+	if c.User == "" && c.Group == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1 // unchanged
+
+	if c.User != "" {
+		u, err := user.Lookup(c.User)
+		if err != nil {
+			u, err = user.LookupId(c.User)
+		}
+		if err != nil {
+			return err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return err
+		}
+	}
+
+	if c.Group != "" {
+		g, err := user.LookupGroup(c.Group)
+		if err != nil {
+			g, err = user.LookupGroupId(c.Group)
+		}
+		if err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return err
+		}
+	}
+
+	return os.Chown(c.Addr, uid, gid)
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseListenerConfig(t *testing.T) {
+	tests := []struct {
+		spec        string
+		expected    ListenerConfig
+		expectedErr bool
+	}{
+		{
+			spec:     ":8080",
+			expected: ListenerConfig{Network: "tcp", Addr: ":8080"},
+		},
+		{
+			spec:     "localhost:8080",
+			expected: ListenerConfig{Network: "tcp", Addr: "localhost:8080"},
+		},
+		{
+			spec:     "127.0.0.1:8080",
+			expected: ListenerConfig{Network: "tcp", Addr: "127.0.0.1:8080"},
+		},
+		{
+			spec:     "tcp://[::1]:8443?tls=true",
+			expected: ListenerConfig{Network: "tcp", Addr: "[::1]:8443", TLS: true},
+		},
+		{
+			spec:     "unix:///run/go-webserver.sock?mode=0660&user=www&group=1000",
+			expected: ListenerConfig{Network: "unix", Addr: "/run/go-webserver.sock", Mode: 0o660, User: "www", Group: "1000"},
+		},
+		{
+			spec:     "unix://relative.sock",
+			expected: ListenerConfig{Network: "unix", Addr: "relative.sock"},
+		},
+		{spec: "8080", expectedErr: true},
+		{spec: "udp://:8080", expectedErr: true},
+		{spec: "unix://", expectedErr: true},
+		{spec: "tcp://:8080?mode=0660", expectedErr: true},
+		{spec: "tcp://:8080?tls=maybe", expectedErr: true},
+		{spec: "unix:///run/go-webserver.sock?mode=rw", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseListenerConfig(tt.spec)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+
+			// String returns a spec that parses to the same config
+			if err == nil {
+				again, err := ParseListenerConfig(got.String())
+				if err != nil || again != got {
+					t.Errorf("expected %+v from %q, got %+v, %v", got, got.String(), again, err)
+				}
+			}
+		})
+	}
+}
+
+func TestServerListeners(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "server")
+	socket := filepath.Join(dir, "server.sock")
+
+	// a stale socket from a previous process is replaced
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	config := DefaultServerConfig()
+	config.Addr = "127.0.0.1:0"
+	config.CertFile = certFile
+	config.KeyFile = keyFile
+	config.Listeners = []ListenerConfig{
+		{Network: "tcp", Addr: "127.0.0.1:0"},
+		{Network: "unix", Addr: socket, Mode: 0o600},
+	}
+	srv := NewServer(config, handler.NewServeMux())
+
+	listeners, err := srv.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 3 {
+		t.Fatalf("expected 3 listeners, got %d", len(listeners))
+	}
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() { errChan <- srv.ServeListeners(ctx, listeners) }()
+
+	rootCAs, err := LoadCertPool(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		url       string
+		transport *http.Transport
+	}{
+		{
+			name:      "TLS",
+			url:       "https://" + listeners[0].Addr().String() + "/hello",
+			transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, ServerName: "server"}},
+		},
+		{
+			name:      "TCP",
+			url:       "http://" + listeners[1].Addr().String() + "/hello",
+			transport: &http.Transport{},
+		},
+		{
+			name: "Unix",
+			url:  "http://unix/hello",
+			transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: tt.transport, Timeout: 5 * time.Second}
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			tt.transport.CloseIdleConnections()
+
+			if string(body) != "hello\n" {
+				t.Errorf("expected 'hello', got %q", body)
+			}
+		})
+	}
+
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// all listeners are closed, and the socket is removed
+	for _, l := range listeners {
+		if conn, err := net.Dial(l.Addr().Network(), l.Addr().String()); err == nil {
+			conn.Close()
+			t.Errorf("expected %v to be closed", l.Addr())
+		}
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("expected socket to be removed, got %v", err)
+	}
+}
+
+func TestListenSocketInUse(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "server.sock")
+
+	live, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+
+	ln, err := ListenerConfig{Network: "unix", Addr: socket}.Listen()
+	if err == nil {
+		ln.Close()
+		t.Fatal("expected error for socket in use")
+	}
+	if !errors.Is(err, errSocketInUse) {
+		t.Errorf("expected %v, got %v", errSocketInUse, err)
+	}
+
+	// the live socket still accepts connections
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("expected live socket to be kept, got %v", err)
+	}
+	conn.Close()
+}
+
+func TestListenUnixUmask(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "server.sock")
+
+	umask := syscall.Umask(0o022)
+	defer syscall.Umask(umask)
+
+	// the socket is only accessible by the owner before setOwnership
+	ln, err := ListenerConfig{Network: "unix", Addr: socket, Mode: 0o666}.listenUnix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		t.Errorf("expected socket to be only accessible by the owner, got %v", perm)
+	}
+
+	// the umask is restored
+	if got := syscall.Umask(0o022); got != 0o022 {
+		t.Errorf("expected umask 0022, got %04o", got)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// LogLevelMap maps log level names to values.
+// Lowercase names are used to allow case insensitive lookups.
+var LogLevelMap = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// LogLevels returns a comma-separated string of available log level.
+func LogLevels() string {
+	keys := make([]string, 0, len(LogLevelMap))
+
+	// get all keys
+	for key := range LogLevelMap {
+		keys = append(keys, key)
+	}
+
+	// sort by key value, i.e., slog.Level
+	sort.Slice(keys, func(i, j int) bool {
+		return LogLevelMap[keys[i]] < LogLevelMap[keys[j]]
+	})
+
+	return strings.Join(keys, ", ")
+}
+
+// LogLevel returns the corresponding slog.Level value.
+// The lookup is case insensitive.
+func LogLevel(logLevelStr string) (slog.Level, error) {
+	// assume LogLevelMap keys are lowercase
+	logLevelStr = strings.ToLower(logLevelStr)
+
+	logLevel, ok := LogLevelMap[logLevelStr]
+	if !ok {
+		return slog.LevelInfo, fmt.Errorf("invalid loglevel: %s", logLevelStr)
+	}
+
+	return logLevel, nil
+}
+
+// LogLevelName returns the name of level as used in LogLevelMap, or the
+// slog.Level string if level is not in LogLevelMap.
+func LogLevelName(level slog.Level) string {
+	for name, value := range LogLevelMap {
+		if value == level {
+			return name
+		}
+	}
+	return level.String()
+}
+
+// logLevel is the level used by the handler created by InitLog.
+// It can be changed at runtime using SetLogLevel.
+var logLevel = new(slog.LevelVar)
+
+// CurrentLogLevel returns the current level of the logger created by InitLog.
+func CurrentLogLevel() slog.Level {
+	return logLevel.Level()
+}
+
+// SetLogLevel changes the level of the logger created by InitLog.
+// The change is logged with reason, which describes what caused the change.
+func SetLogLevel(level slog.Level, reason string) {
+	prev := logLevel.Level()
+	logLevel.Set(level)
+
+	// log at the higher level to ensure the change is recorded
+	slog.Log(context.Background(), max(prev, level, slog.LevelInfo), "log level changed",
+		slog.String("from", LogLevelName(prev)),
+		slog.String("to", LogLevelName(level)),
+		slog.String("reason", reason),
+	)
+}
+
+// StepLogLevel changes the level of the logger created by InitLog to the next
+// level in LogLevelMap that is higher, if steps is positive, or lower, if
+// steps is negative, repeated for the number of steps. The level does not go
+// beyond the highest or lowest level in LogLevelMap. The new level is returned.
+func StepLogLevel(steps int, reason string) slog.Level {
+	levels := make([]slog.Level, 0, len(LogLevelMap))
+	for _, level := range LogLevelMap {
+		levels = append(levels, level)
+	}
+	slices.Sort(levels)
+
+	// find the position of the current level, or where it would be
+	i, found := slices.BinarySearch(levels, CurrentLogLevel())
+	if !found && steps > 0 {
+		i--
+	}
+	i = min(max(i+steps, 0), len(levels)-1)
+
+	SetLogLevel(levels[i], reason)
+
+	return levels[i]
+}
+
+// ValidLogTypes lists the handler types accepted by InitLog.
+var ValidLogTypes = []string{"json", "text"}
+
+// logFile is the log file opened by InitLog, or nil if logging to Stderr.
+var logFile *LogFile
+
+// ReopenLog reopens the log file opened by InitLog, which allows the file to
+// be rotated externally without restarting. It does nothing if InitLog was
+// not given a file name.
+func ReopenLog() error {
+	if logFile == nil {
+		return nil
+	}
+
+	err := logFile.Reopen()
+	if err != nil {
+		return fmt.Errorf("ReopenLog: %w", err)
+	}
+
+	return nil
+}
+
+// InitLog initializes logging for the application.
+// If name is not empty, the log file is rotated as specified by rotation.
+func InitLog(name, handlerType string, level slog.Level, addSource bool, rotation LogRotation) error {
+	// configure log writter
+	var w io.Writer = os.Stderr // default to Stderr is logFileName empty
+	logFile = nil
+	if name != "" {
+		file, err := OpenLogFile(name, rotation)
+		if err != nil {
+			return fmt.Errorf("InitLog: %w", err)
+		}
+		// do not close the file since it must remain open
+		logFile = file
+		w = file
+	}
+
+	// configure logger with a level that can be changed by SetLogLevel
+	logLevel.Set(level)
+	opts := &slog.HandlerOptions{
+		AddSource: addSource,
+		Level:     logLevel,
+	}
+
+	// configure handler
+	var handler slog.Handler
+	switch handlerType {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+
+	slog.Info("InitLog",
+		slog.Group("log",
+			slog.String("Filename", name),
+			slog.String("Type", handlerType),
+			slog.String("Level", level.String()),
+			slog.Bool("AddSource", addSource),
+			slog.Int64("MaxSize", rotation.MaxSize),
+			slog.Bool("Daily", rotation.Daily),
+			slog.Int("MaxFiles", rotation.MaxFiles),
+			slog.Bool("Compress", rotation.Compress),
+		),
+	)
+
+	return nil
+}
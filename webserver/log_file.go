@@ -0,0 +1,304 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logOpenFileFlag = os.O_APPEND | os.O_CREATE | os.O_WRONLY
+
+	ownerReadWrite  = 0o600
+	logOpenFileMode = ownerReadWrite
+
+	// rotatedTimeFormat is appended to the name of a rotated log file.
+	rotatedTimeFormat = "20060102-150405.000000000"
+)
+
+// logFileErrors receives errors that cannot be returned by a LogFile, such as
+// failing to compress a rotated file.
+var logFileErrors io.Writer = os.Stderr
+
+// LogRotation configures when a LogFile is rotated and which rotated files
+// are kept. The zero value disables rotation.
+type LogRotation struct {
+	MaxSize  int64 // MaxSize is the size in bytes that triggers rotation, or zero for no limit.
+	Daily    bool  // Daily rotates the file on the first write of each day.
+	MaxFiles int   // MaxFiles is the number of rotated files to keep, or zero to keep all.
+	Compress bool  // Compress gzips rotated files.
+}
+
+// enabled reports whether r rotates the file.
+func (r LogRotation) enabled() bool {
+	return r.MaxSize > 0 || r.Daily
+}
+
+// LogFile is an io.Writer for a log file that is safe for concurrent use.
+// It can be reopened, e.g., after the file is renamed by an external log
+// rotation tool, or rotated automatically based on its LogRotation.
+type LogFile struct {
+	mu       sync.Mutex
+	name     string
+	file     *os.File
+	rotation LogRotation
+	size     int64     // size is the current size of file.
+	day      time.Time // day is the start of the day file was last written.
+
+	rotateFailed bool // rotateFailed is set until a failed rotation succeeds.
+
+	cleanupMu sync.Mutex     // cleanupMu serializes compressing and removing rotated files.
+	cleanupWG sync.WaitGroup // cleanupWG waits for cleanup in the background.
+}
+
+// OpenLogFile opens the named file for appending, creating it if needed.
+// The file is rotated as specified by rotation.
+func OpenLogFile(name string, rotation LogRotation) (*LogFile, error) {
+	f := &LogFile{name: name, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file by name and records its size and modification day.
+// The caller must hold f.mu or have exclusive access to f.
+func (f *LogFile) open() error {
+	file, err := os.OpenFile(f.name, logOpenFileFlag, logOpenFileMode)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.day = startOfDay(time.Now())
+	if f.size > 0 {
+		f.day = startOfDay(info.ModTime())
+	}
+
+	return nil
+}
+
+// startOfDay returns midnight at the start of the day of t.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// Name returns the name of the file.
+func (f *LogFile) Name() string {
+	return f.name
+}
+
+// Write writes p to the current file, rotating the file first if needed.
+func (f *LogFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+
+	var rotated string
+	if f.needsRotation(len(p)) {
+		var err error
+		rotated, err = f.rotate()
+		switch {
+		case err != nil && !f.rotateFailed:
+			// rotate keeps the current file open if a new file is not, and
+			// the error is reported once rather than on every write
+			fmt.Fprintf(logFileErrors, "LogFile: failed to rotate %s: %v\n", f.name, err)
+			f.rotateFailed = true
+		case err == nil && f.rotateFailed:
+			fmt.Fprintf(logFileErrors, "LogFile: rotated %s\n", f.name)
+			f.rotateFailed = false
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	f.day = startOfDay(time.Now())
+
+	// compress and remove rotated files without blocking the writer; Add is
+	// called while holding f.mu so Close waits for it
+	if rotated != "" {
+		f.cleanupWG.Add(1)
+		go func() {
+			defer f.cleanupWG.Done()
+			f.cleanup(rotated)
+		}()
+	}
+
+	f.mu.Unlock()
+
+	return n, err
+}
+
+// needsRotation reports whether the file should be rotated before writing
+// n bytes. The caller must hold f.mu.
+func (f *LogFile) needsRotation(n int) bool {
+	if !f.rotation.enabled() || f.size == 0 {
+		return false
+	}
+
+	if f.rotation.MaxSize > 0 && f.size+int64(n) > f.rotation.MaxSize {
+		return true
+	}
+
+	return f.rotation.Daily && startOfDay(time.Now()).After(f.day)
+}
+
+// rotate renames the current file and opens a new one, returning the
+// rotated file name. If the new file cannot be opened, the current file is
+// renamed back and kept open. The caller must hold f.mu.
+func (f *LogFile) rotate() (string, error) {
+	rotated := f.name + "." + time.Now().Format(rotatedTimeFormat)
+
+	// the current file can be written while it is renamed
+	if err := os.Rename(f.name, rotated); err != nil {
+		return "", err
+	}
+
+	prev := f.file
+	if err := f.open(); err != nil {
+		if renameErr := os.Rename(rotated, f.name); renameErr != nil {
+			fmt.Fprintf(logFileErrors, "LogFile: failed to restore %s: %v\n", f.name, renameErr)
+		}
+		return "", err
+	}
+
+	// the file is rotated even if the previous file fails to close
+	return rotated, prev.Close()
+}
+
+// cleanup compresses the rotated file, if configured, and removes the oldest
+// rotated files beyond MaxFiles.
+func (f *LogFile) cleanup(rotated string) {
+	f.cleanupMu.Lock()
+	defer f.cleanupMu.Unlock()
+
+	if f.rotation.Compress {
+		if err := compressFile(rotated); err != nil {
+			fmt.Fprintf(logFileErrors, "LogFile: failed to compress %s: %v\n", rotated, err)
+		}
+	}
+
+	if f.rotation.MaxFiles > 0 {
+		if err := f.removeOldFiles(); err != nil {
+			fmt.Fprintf(logFileErrors, "LogFile: failed to remove old files: %v\n", err)
+		}
+	}
+}
+
+// RotatedFiles returns the names of the rotated files, oldest first.
+func (f *LogFile) RotatedFiles() ([]string, error) {
+	matches, err := filepath.Glob(f.name + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(f.name) + "."
+	rotated := matches[:0]
+	for _, match := range matches {
+		ts := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), prefix), ".gz")
+		if _, err := time.Parse(rotatedTimeFormat, ts); err == nil {
+			rotated = append(rotated, match)
+		}
+	}
+
+	// the time format sorts chronologically
+	sort.Strings(rotated)
+
+	return rotated, nil
+}
+
+// removeOldFiles removes the oldest rotated files beyond MaxFiles.
+func (f *LogFile) removeOldFiles() error {
+	rotated, err := f.RotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	for len(rotated) > f.rotation.MaxFiles {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+
+	return nil
+}
+
+// compressFile replaces name with a gzip compressed name.gz.
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, logOpenFileMode)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+// Reopen opens the file by name again and closes the previous file.
+// If the open fails, writes continue to the previous file.
+func (f *LogFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	prev := f.file
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	return prev.Close()
+}
+
+// Close closes the file, after waiting for rotated files to be compressed
+// and removed.
+func (f *LogFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.cleanupWG.Wait()
+
+	return f.file.Close()
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLogFileRotation(t *testing.T) {
+	tests := []struct {
+		name          string
+		rotation      LogRotation
+		writes        int
+		expectedFiles int
+	}{
+		{
+			name:     "No rotation",
+			writes:   10,
+			rotation: LogRotation{},
+		},
+		{
+			name:          "Size",
+			rotation:      LogRotation{MaxSize: 25},
+			writes:        10,
+			expectedFiles: 4,
+		},
+		{
+			name:          "Size with max files",
+			rotation:      LogRotation{MaxSize: 25, MaxFiles: 2},
+			writes:        10,
+			expectedFiles: 2,
+		},
+		{
+			name:          "Size with compression",
+			rotation:      LogRotation{MaxSize: 25, MaxFiles: 3, Compress: true},
+			writes:        10,
+			expectedFiles: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "test.log")
+
+			f, err := OpenLogFile(name, tt.rotation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// each line is 10 bytes, so two lines fit in MaxSize
+			for i := 0; i < tt.writes; i++ {
+				fmt.Fprintf(f, "line %04d\n", i)
+			}
+
+			// wait for the rotated files to be compressed and removed
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rotated, err := f.RotatedFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rotated) != tt.expectedFiles {
+				t.Fatalf("expected %d rotated files, got %d: %v", tt.expectedFiles, len(rotated), rotated)
+			}
+
+			for _, file := range rotated {
+				if strings.HasSuffix(file, ".gz") != tt.rotation.Compress {
+					t.Errorf("expected compressed %v for %s", tt.rotation.Compress, file)
+				}
+				if got := readLogFile(t, file); len(got) != 20 {
+					t.Errorf("expected 20 bytes in %s, got %q", file, got)
+				}
+			}
+
+			// the newest lines are always in the current file
+			last := fmt.Sprintf("line %04d\n", tt.writes-1)
+			if got := readLogFile(t, name); !strings.HasSuffix(got, last) {
+				t.Errorf("expected current file to end with %q, got %q", last, got)
+			}
+		})
+	}
+}
+
+func TestLogFileDailyRotation(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.log")
+
+	f, err := OpenLogFile(name, LogRotation{Daily: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, "today")
+	fmt.Fprintln(f, "still today")
+
+	// pretend the last write was yesterday
+	f.mu.Lock()
+	f.day = f.day.AddDate(0, 0, -1)
+	f.mu.Unlock()
+
+	fmt.Fprintln(f, "tomorrow")
+
+	rotated, err := f.RotatedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 1 {
+		t.Fatalf("expected 1 rotated file, got %v", rotated)
+	}
+	if got := readLogFile(t, rotated[0]); got != "today\nstill today\n" {
+		t.Errorf("unexpected rotated content %q", got)
+	}
+	if got := readLogFile(t, name); got != "tomorrow\n" {
+		t.Errorf("unexpected current content %q", got)
+	}
+}
+
+func TestLogFileConcurrentWrites(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.log")
+
+	f, err := OpenLogFile(name, LogRotation{MaxSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const writers, lines = 8, 100
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				fmt.Fprintf(f, "line %04d\n", i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := f.RotatedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int
+	for _, file := range append(rotated, name) {
+		content := readLogFile(t, file)
+		if len(content) > 1000 {
+			t.Errorf("expected at most 1000 bytes in %s, got %d", file, len(content))
+		}
+		total += len(content)
+	}
+	if total != writers*lines*10 {
+		t.Errorf("expected %d bytes, got %d", writers*lines*10, total)
+	}
+}
+
+func TestLogFileRotationFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "test.log")
+
+	var stderr bytes.Buffer
+	logFileErrors = &stderr
+	t.Cleanup(func() { logFileErrors = os.Stderr })
+
+	f, err := OpenLogFile(name, LogRotation{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "line %04d\n", 0)
+
+	// the file cannot be renamed or opened again
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// writes continue to the current file
+	for i := 1; i < 3; i++ {
+		if _, err := fmt.Fprintf(f, "line %04d\n", i); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}
+
+	// the failure is reported once, not on every write
+	if got := strings.Count(stderr.String(), "failed to rotate"); got != 1 {
+		t.Errorf("expected 1 rotation error, got %d in %q", got, stderr.String())
+	}
+}
+
+// readLogFile returns the content of the file, decompressing it if needed.
+func readLogFile(t *testing.T, name string) string {
+	t.Helper()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
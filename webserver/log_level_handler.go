@@ -0,0 +1,80 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxLogLevelBody limits the size of a request body for LogLevelHandler.
+const maxLogLevelBody = 64
+
+// RequireToken is middleware that only calls next if the request has an
+// "Authorization: Bearer token" header. Otherwise, it responds with
+// 401 Unauthorized. If token is empty, all requests are rejected.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+			Logger(r.Context()).Warn("unauthorized")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LogLevelHandler responds with the current log level for GET requests and
+// sets the log level to the name in the request body for PUT requests.
+// The names are the same as LogLevelMap.
+func (h *Handler) LogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, http.MethodGet, http.MethodPut) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	if r.Method == http.MethodPut {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxLogLevelBody))
+		if err != nil {
+			logger.Error("failed to read body", "err", err)
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		level, err := LogLevel(strings.TrimSpace(string(body)))
+		if err != nil {
+			logger.Error("invalid log level", "err", err)
+			http.Error(w,
+				fmt.Sprintf("%v\nvalid loglevels: %s", err, LogLevels()),
+				http.StatusBadRequest,
+			)
+			return
+		}
+
+		SetLogLevel(level, "admin request "+RequestIDFromContext(r.Context()))
+	}
+
+	fmt.Fprintln(w, LogLevelName(CurrentLogLevel()))
+}
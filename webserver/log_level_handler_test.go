@@ -0,0 +1,145 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	defer logLevel.Set(CurrentLogLevel())
+	logLevel.Set(slog.LevelInfo)
+
+	const token = "secret"
+	route := handler.AdminRoutes(token)[0]
+
+	tests := []struct {
+		name          string
+		method        string
+		auth          string
+		body          string
+		expectedCode  int
+		expectedBody  string
+		expectedLevel slog.Level
+	}{
+		{
+			name:          "Missing token",
+			method:        http.MethodGet,
+			expectedCode:  http.StatusUnauthorized,
+			expectedBody:  http.StatusText(http.StatusUnauthorized),
+			expectedLevel: slog.LevelInfo,
+		},
+		{
+			name:          "Wrong token",
+			method:        http.MethodGet,
+			auth:          "Bearer wrong",
+			expectedCode:  http.StatusUnauthorized,
+			expectedBody:  http.StatusText(http.StatusUnauthorized),
+			expectedLevel: slog.LevelInfo,
+		},
+		{
+			name:          "Get level",
+			method:        http.MethodGet,
+			auth:          "Bearer " + token,
+			expectedCode:  http.StatusOK,
+			expectedBody:  "info",
+			expectedLevel: slog.LevelInfo,
+		},
+		{
+			name:          "Set level",
+			method:        http.MethodPut,
+			auth:          "Bearer " + token,
+			body:          "DEBUG\n",
+			expectedCode:  http.StatusOK,
+			expectedBody:  "debug",
+			expectedLevel: slog.LevelDebug,
+		},
+		{
+			name:          "Invalid level",
+			method:        http.MethodPut,
+			auth:          "Bearer " + token,
+			body:          "verbose",
+			expectedCode:  http.StatusBadRequest,
+			expectedBody:  "invalid loglevel: verbose\nvalid loglevels: " + LogLevels(),
+			expectedLevel: slog.LevelDebug,
+		},
+		{
+			name:          "Invalid method",
+			method:        http.MethodPost,
+			auth:          "Bearer " + token,
+			expectedCode:  http.StatusMethodNotAllowed,
+			expectedBody:  http.MethodPost + " " + http.StatusText(http.StatusMethodNotAllowed),
+			expectedLevel: slog.LevelDebug,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, route.Pattern, strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			rr := httptest.NewRecorder()
+			route.Handler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("expected status code %d, got %d", tt.expectedCode, rr.Code)
+			}
+
+			body := strings.TrimSpace(rr.Body.String())
+			if body != tt.expectedBody {
+				t.Errorf("expected response body '%s', got '%s'", tt.expectedBody, body)
+			}
+
+			if CurrentLogLevel() != tt.expectedLevel {
+				t.Errorf("expected level %v, got %v", tt.expectedLevel, CurrentLogLevel())
+			}
+		})
+	}
+}
+
+func TestStepLogLevel(t *testing.T) {
+	defer logLevel.Set(CurrentLogLevel())
+
+	tests := []struct {
+		name     string
+		start    slog.Level
+		steps    int
+		expected slog.Level
+	}{
+		{name: "Up", start: slog.LevelInfo, steps: 1, expected: slog.LevelWarn},
+		{name: "Down", start: slog.LevelInfo, steps: -1, expected: slog.LevelDebug},
+		{name: "Up two", start: slog.LevelDebug, steps: 2, expected: slog.LevelWarn},
+		{name: "Above highest", start: slog.LevelError, steps: 1, expected: slog.LevelError},
+		{name: "Below lowest", start: slog.LevelDebug, steps: -1, expected: slog.LevelDebug},
+		{name: "Up from between", start: slog.LevelInfo + 2, steps: 1, expected: slog.LevelWarn},
+		{name: "Down from between", start: slog.LevelInfo + 2, steps: -1, expected: slog.LevelInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logLevel.Set(tt.start)
+
+			got := StepLogLevel(tt.steps, "test")
+			if got != tt.expected || CurrentLogLevel() != tt.expected {
+				t.Errorf("expected level %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
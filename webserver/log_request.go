@@ -0,0 +1,111 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LoggerKey is used as a context key for the custom logger.
+type LoggerKey int
+
+const loggerKey LoggerKey = iota
+
+// LogRequest middleware logs incoming HTTP requests and their completion.
+// It adds a Logger to the request context that can be used by child handlers to include request information.
+// The client IP, scheme, and host are from ProxyConfig.AddClientInfo, if used.
+// The completion record includes the response status, bytes written, and duration.
+// For a hijacked connection, it includes hijacked rather than bytes written.
+func LogRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		client, ok := ClientInfoFromContext(r.Context())
+		if !ok {
+			client = ProxyConfig{}.ResolveClient(r)
+		}
+
+		attrs := []any{
+			slog.String("method", r.Method),
+			slog.String("url", r.URL.String()),
+			slog.String("ip", client.IP),
+			slog.String("scheme", client.Scheme),
+			slog.String("host", client.Host),
+			slog.String("requestID", RequestIDFromContext(r.Context())),
+			slog.String("traceID", TraceIDFromContext(r.Context())),
+			slog.String("spanID", SpanIDFromContext(r.Context())),
+			slog.String("proto", r.Proto),
+			slog.String("tls", TLSVersion(r)),
+			slog.String("userAgent", r.UserAgent()),
+		}
+		if header, ok := ProxyHeaderFromContext(r.Context()); ok {
+			attrs = append(attrs, slog.Any("proxy", header))
+		}
+
+		logger := slog.With(slog.Group("request", attrs...))
+		logger.Info("LogRequest")
+
+		// add new logger to context
+		ctx := context.WithValue(r.Context(), loggerKey, logger)
+
+		// capture the response status and size
+		rw := NewResponseWriter(w)
+
+		// server the request with the updated context
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		// the bytes written to a hijacked connection are not known
+		response := []any{slog.Int("status", rw.Status())}
+		if rw.Hijacked() {
+			response = append(response, slog.Bool("hijacked", true))
+		} else {
+			response = append(response, slog.Int64("bytes", rw.BytesWritten()))
+		}
+		response = append(response, slog.Duration("duration", time.Since(start)))
+
+		logger.Info("LogRequest completed", slog.Group("response", response...))
+	})
+}
+
+// TLSVersion returns the name of the TLS version used by r, or "" if r was
+// not received over TLS.
+func TLSVersion(r *http.Request) string {
+	if r.TLS == nil {
+		return ""
+	}
+	return tls.VersionName(r.TLS.Version)
+}
+
+// Logger returns the custom logger if present, otherwise default logger.
+func Logger(ctx context.Context) *slog.Logger {
+	// if context is nil, return the default logger.
+	if ctx == nil {
+		return slog.Default()
+	}
+
+	// attempt to retrieve the logger from the context using the loggerKey
+	logger, ok := ctx.Value(loggerKey).(*slog.Logger)
+
+	// if the logger is not present in the context or has an incorrect type, return the default logger.
+	if !ok {
+		return slog.Default()
+	}
+
+	// return the custom logger from the context
+	return logger
+}
@@ -0,0 +1,383 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProxyHeaderTimeout is the default time allowed to read a PROXY
+// protocol header.
+const DefaultProxyHeaderTimeout = 5 * time.Second
+
+const (
+	// proxyV1MaxLength is the maximum length of a version 1 header.
+	proxyV1MaxLength = 107
+
+	// proxyV2HeaderLength is the length of the fixed part of a version 2 header.
+	proxyV2HeaderLength = 16
+
+	// ProxyTLVTypeAWS is the TLV type used by AWS, where a value beginning
+	// with subtype 0x01 holds the VPC endpoint ID.
+	ProxyTLVTypeAWS = 0xEA
+)
+
+// proxyV2Signature starts every version 2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrInvalidProxyHeader indicates a missing or malformed PROXY protocol header.
+var ErrInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+// ProxyTLV is a type-length-value vector from a version 2 PROXY header.
+type ProxyTLV struct {
+	Type  byte
+	Value []byte
+}
+
+// ProxyHeader holds a PROXY protocol header as sent by load balancers, e.g.,
+// HAProxy or AWS Network Load Balancers.
+// See https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt.
+type ProxyHeader struct {
+	Version     int        // Version is 1 or 2.
+	Local       bool       // Local is true for a connection from the proxy itself, e.g., a health check.
+	Source      net.Addr   // Source is the original client address, or nil if unknown.
+	Destination net.Addr   // Destination is the original destination address, or nil if unknown.
+	TLVs        []ProxyTLV // TLVs are the type-length-value vectors of a version 2 header.
+}
+
+// AWSVPCEndpointID returns the AWS VPC endpoint ID from the TLVs, if any.
+func (h *ProxyHeader) AWSVPCEndpointID() string {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == ProxyTLVTypeAWS && len(tlv.Value) > 1 && tlv.Value[0] == 0x01 {
+			return string(tlv.Value[1:])
+		}
+	}
+	return ""
+}
+
+// LogValue implements slog.LogValuer.
+func (h *ProxyHeader) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("version", h.Version),
+		slog.Bool("local", h.Local),
+	}
+	if h.Source != nil {
+		attrs = append(attrs, slog.String("source", h.Source.String()))
+	}
+	if h.Destination != nil {
+		attrs = append(attrs, slog.String("destination", h.Destination.String()))
+	}
+	if id := h.AWSVPCEndpointID(); id != "" {
+		attrs = append(attrs, slog.String("vpceID", id))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// ReadProxyHeader reads a version 1 or 2 PROXY protocol header from r.
+func ReadProxyHeader(r *bufio.Reader) (*ProxyHeader, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err == nil && bytes.Equal(sig, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+
+	prefix, err := r.Peek(6)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProxyHeader, err)
+	}
+	if string(prefix) == "PROXY " {
+		return readProxyHeaderV1(r)
+	}
+
+	return nil, ErrInvalidProxyHeader
+}
+
+// readProxyHeaderV1 reads a human-readable version 1 header, e.g.,
+// "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func readProxyHeaderV1(r *bufio.Reader) (*ProxyHeader, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProxyHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: line too long or not terminated", ErrInvalidProxyHeader)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	header := &ProxyHeader{Version: 1}
+
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return header, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProxyHeader, line)
+	}
+
+	src, err := parseProxyV1Addr(fields[2], fields[4], fields[1] == "TCP4")
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseProxyV1Addr(fields[3], fields[5], fields[1] == "TCP4")
+	if err != nil {
+		return nil, err
+	}
+	header.Source, header.Destination = src, dst
+
+	return header, nil
+}
+
+// parseProxyV1Addr parses the address and port fields of a version 1 header.
+func parseProxyV1Addr(addrStr, portStr string, ipv4 bool) (*net.TCPAddr, error) {
+	addr, err := netip.ParseAddr(addrStr)
+	if err != nil || addr.Is4() != ipv4 {
+		return nil, fmt.Errorf("%w: invalid address %q", ErrInvalidProxyHeader, addrStr)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || (len(portStr) > 1 && portStr[0] == '0') {
+		return nil, fmt.Errorf("%w: invalid port %q", ErrInvalidProxyHeader, portStr)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+// readProxyHeaderV2 reads a binary version 2 header.
+func readProxyHeaderV2(r *bufio.Reader) (*ProxyHeader, error) {
+	fixed := make([]byte, proxyV2HeaderLength)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProxyHeader, err)
+	}
+
+	verCmd, family := fixed[12], fixed[13]
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidProxyHeader, verCmd>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProxyHeader, err)
+	}
+
+	header := &ProxyHeader{Version: 2}
+	switch verCmd & 0x0f {
+	case 0x0: // LOCAL
+		header.Local = true
+		return header, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidProxyHeader, verCmd&0x0f)
+	}
+
+	// address length based on the address family, ignoring the transport
+	var addrLen int
+	switch family >> 4 {
+	case 0x0: // AF_UNSPEC
+	case 0x1: // AF_INET
+		addrLen = 12
+	case 0x2: // AF_INET6
+		addrLen = 36
+	case 0x3: // AF_UNIX
+		addrLen = 216
+	default:
+		return nil, fmt.Errorf("%w: unsupported address family %d", ErrInvalidProxyHeader, family>>4)
+	}
+	if len(payload) < addrLen {
+		return nil, fmt.Errorf("%w: short address block", ErrInvalidProxyHeader)
+	}
+
+	switch family >> 4 {
+	case 0x1, 0x2:
+		n := (addrLen - 4) / 2
+		src, _ := netip.AddrFromSlice(payload[:n])
+		dst, _ := netip.AddrFromSlice(payload[n : 2*n])
+		sport := binary.BigEndian.Uint16(payload[2*n:])
+		dport := binary.BigEndian.Uint16(payload[2*n+2:])
+		header.Source = net.TCPAddrFromAddrPort(netip.AddrPortFrom(src, sport))
+		header.Destination = net.TCPAddrFromAddrPort(netip.AddrPortFrom(dst, dport))
+	case 0x3:
+		header.Source = &net.UnixAddr{Name: string(bytes.TrimRight(payload[:108], "\x00")), Net: "unix"}
+		header.Destination = &net.UnixAddr{Name: string(bytes.TrimRight(payload[108:216], "\x00")), Net: "unix"}
+	}
+
+	tlvs := payload[addrLen:]
+	for len(tlvs) > 0 {
+		if len(tlvs) < 3 {
+			return nil, fmt.Errorf("%w: short TLV", ErrInvalidProxyHeader)
+		}
+		n := int(binary.BigEndian.Uint16(tlvs[1:3]))
+		if len(tlvs) < 3+n {
+			return nil, fmt.Errorf("%w: short TLV value", ErrInvalidProxyHeader)
+		}
+		header.TLVs = append(header.TLVs, ProxyTLV{Type: tlvs[0], Value: tlvs[3 : 3+n]})
+		tlvs = tlvs[3+n:]
+	}
+
+	return header, nil
+}
+
+// ProxyProtocolListener wraps a net.Listener to read a PROXY protocol header
+// from connections from trusted sources. The header is read on first use of
+// the connection, so a slow client does not block Accept.
+type ProxyProtocolListener struct {
+	net.Listener
+
+	// TrustedSources are the addresses of proxies that must send a header.
+	// Connections from other addresses are used as is. If empty, all
+	// connections must send a header.
+	TrustedSources []netip.Prefix
+
+	// HeaderTimeout limits the time to read the header. If zero,
+	// DefaultProxyHeaderTimeout is used.
+	HeaderTimeout time.Duration
+}
+
+// Accept waits for and returns the next connection. Connections from trusted
+// sources are returned as a *ProxyConn.
+func (l *ProxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(l.TrustedSources) > 0 {
+		addr, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+		if err != nil || !(ProxyConfig{TrustedProxies: l.TrustedSources}).trusted(addr.Addr()) {
+			return conn, nil
+		}
+	}
+
+	timeout := l.HeaderTimeout
+	if timeout == 0 {
+		timeout = DefaultProxyHeaderTimeout
+	}
+
+	return &ProxyConn{Conn: conn, timeout: timeout}, nil
+}
+
+// ProxyConn is a connection that begins with a PROXY protocol header.
+// RemoteAddr and LocalAddr return the addresses from the header, if any.
+type ProxyConn struct {
+	net.Conn
+
+	timeout time.Duration
+	once    sync.Once
+	reader  *bufio.Reader
+	header  *ProxyHeader
+	err     error
+}
+
+// readHeader reads the header once, within the timeout.
+func (c *ProxyConn) readHeader() {
+	c.once.Do(func() {
+		c.reader = bufio.NewReader(c.Conn)
+
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		c.header, c.err = ReadProxyHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+// ProxyHeader returns the PROXY protocol header of the connection.
+func (c *ProxyConn) ProxyHeader() (*ProxyHeader, error) {
+	c.readHeader()
+	return c.header, c.err
+}
+
+// Read reads data following the header. If the header is invalid, the error
+// is returned.
+func (c *ProxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the source address from the header, if known,
+// otherwise the address of the proxy.
+func (c *ProxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address from the header, if known,
+// otherwise the local address of the connection.
+func (c *ProxyConn) LocalAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+// ProxyAddr returns the address of the proxy that sent the header.
+func (c *ProxyConn) ProxyAddr() net.Addr {
+	return c.Conn.RemoteAddr()
+}
+
+// proxyConnContext adds the *ProxyConn, if any, to the context of each
+// connection. It is used as http.Server.ConnContext.
+func proxyConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if proxyConn, ok := conn.(*ProxyConn); ok {
+		return context.WithValue(ctx, proxyConnKey, proxyConn)
+	}
+	return ctx
+}
+
+// ProxyHeaderFromContext returns the PROXY protocol header of the connection
+// of a request from ctx and whether it was present.
+func ProxyHeaderFromContext(ctx context.Context) (*ProxyHeader, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	conn, ok := ctx.Value(proxyConnKey).(*ProxyConn)
+	if !ok {
+		return nil, false
+	}
+	header, err := conn.ProxyHeader()
+	if err != nil {
+		return nil, false
+	}
+	return header, true
+}
+
+// FormatTLV returns a TLV formatted for display, e.g., "0xEA: 01767063652d...".
+func FormatTLV(tlv ProxyTLV) string {
+	return fmt.Sprintf("0x%02X: %s", tlv.Type, hex.EncodeToString(tlv.Value))
+}
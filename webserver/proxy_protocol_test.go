@@ -0,0 +1,247 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2 returns a version 2 header with the command, family, and payload.
+func proxyV2(cmd, family byte, payload []byte) []byte {
+	b := append([]byte{}, proxyV2Signature...)
+	b = append(b, 0x20|cmd, family)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	return append(b, payload...)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	// IPv4 addresses and ports followed by an AWS VPC endpoint TLV
+	ipv4 := []byte{192, 0, 2, 1, 192, 0, 2, 2, 0xdc, 0x04, 0x01, 0xbb}
+	vpce := append([]byte{0x01}, "vpce-08d2bf15fac5001c9"...)
+	tlv := append([]byte{ProxyTLVTypeAWS}, binary.BigEndian.AppendUint16(nil, uint16(len(vpce)))...)
+	tlv = append(tlv, vpce...)
+
+	ipv6 := make([]byte, 36)
+	copy(ipv6[0:16], netip.MustParseAddr("2001:db8::1").AsSlice())
+	copy(ipv6[16:32], netip.MustParseAddr("2001:db8::2").AsSlice())
+	binary.BigEndian.PutUint16(ipv6[32:], 1234)
+	binary.BigEndian.PutUint16(ipv6[34:], 443)
+
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedErr bool
+		version     int
+		local       bool
+		source      string
+		destination string
+		vpceID      string
+	}{
+		{
+			name:        "v1 TCP4",
+			input:       []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"),
+			version:     1,
+			source:      "192.0.2.1:56324",
+			destination: "192.0.2.2:443",
+		},
+		{
+			name:        "v1 TCP6",
+			input:       []byte("PROXY TCP6 2001:db8::1 2001:db8::2 1234 443\r\n"),
+			version:     1,
+			source:      "[2001:db8::1]:1234",
+			destination: "[2001:db8::2]:443",
+		},
+		{
+			name:    "v1 UNKNOWN",
+			input:   []byte("PROXY UNKNOWN\r\n"),
+			version: 1,
+		},
+		{
+			name:        "v1 mismatched family",
+			input:       []byte("PROXY TCP4 2001:db8::1 192.0.2.2 1234 443\r\n"),
+			expectedErr: true,
+		},
+		{
+			name:        "v1 not terminated",
+			input:       []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\n"),
+			expectedErr: true,
+		},
+		{
+			name:        "v1 too long",
+			input:       []byte("PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n"),
+			expectedErr: true,
+		},
+		{
+			name:        "v2 TCP4 with TLV",
+			input:       proxyV2(0x1, 0x11, append(ipv4, tlv...)),
+			version:     2,
+			source:      "192.0.2.1:56324",
+			destination: "192.0.2.2:443",
+			vpceID:      "vpce-08d2bf15fac5001c9",
+		},
+		{
+			name:        "v2 TCP6",
+			input:       proxyV2(0x1, 0x21, ipv6),
+			version:     2,
+			source:      "[2001:db8::1]:1234",
+			destination: "[2001:db8::2]:443",
+		},
+		{
+			name:    "v2 LOCAL",
+			input:   proxyV2(0x0, 0x00, nil),
+			version: 2,
+			local:   true,
+		},
+		{
+			name:        "v2 short address",
+			input:       proxyV2(0x1, 0x11, ipv4[:8]),
+			expectedErr: true,
+		},
+		{
+			name:        "v2 short TLV",
+			input:       proxyV2(0x1, 0x11, append(ipv4, tlv[:5]...)),
+			expectedErr: true,
+		},
+		{
+			name:        "No header",
+			input:       []byte("GET / HTTP/1.1\r\n\r\n"),
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(io.MultiReader(bytes.NewReader(tt.input), strings.NewReader("payload")))
+
+			header, err := ReadProxyHeader(r)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidProxyHeader) {
+					t.Errorf("expected ErrInvalidProxyHeader, got %v", err)
+				}
+				return
+			}
+
+			if header.Version != tt.version || header.Local != tt.local {
+				t.Errorf("unexpected header %+v", header)
+			}
+			if got := addrString(header.Source); got != tt.source {
+				t.Errorf("expected source %q, got %q", tt.source, got)
+			}
+			if got := addrString(header.Destination); got != tt.destination {
+				t.Errorf("expected destination %q, got %q", tt.destination, got)
+			}
+			if got := header.AWSVPCEndpointID(); got != tt.vpceID {
+				t.Errorf("expected VPC endpoint ID %q, got %q", tt.vpceID, got)
+			}
+
+			// the data after the header is unchanged
+			if rest, _ := io.ReadAll(r); string(rest) != "payload" {
+				t.Errorf("expected remaining 'payload', got %q", rest)
+			}
+		})
+	}
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func TestServerProxyProtocol(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultServerConfig()
+	config.ProxyProtocol = true
+	config.ProxyProtocolSources = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	config.ProxyHeaderTimeout = time.Second
+	srv := NewServer(config, ProxyConfig{}.AddClientInfo(http.HandlerFunc(handler.RemoteHandler)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Serve(ctx, ln)
+
+	tests := []struct {
+		name     string
+		header   string
+		status   int
+		expected []string
+	}{
+		{
+			name:   "Header",
+			header: "PROXY TCP4 198.51.100.7 192.0.2.2 56324 443\r\n",
+			status: http.StatusOK,
+			expected: []string{
+				"RemoteAddr: 198.51.100.7:56324",
+				"Client-IP: 198.51.100.7",
+				"Client-IP-Source: PROXY",
+				"Proxy-Protocol-Version: 1",
+				"Proxy-Destination: 192.0.2.2:443",
+			},
+		},
+		{
+			name:     "Missing header",
+			header:   "",
+			status:   http.StatusBadRequest,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			io.WriteString(conn, tt.header+"GET /remote HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+
+			for _, line := range tt.expected {
+				if !strings.Contains(string(body), line+"\n") {
+					t.Errorf("expected %q in body:\n%s", line, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ACMEChallengePath is the path prefix of ACME HTTP-01 challenges.
+const ACMEChallengePath = "/.well-known/acme-challenge/"
+
+// RedirectToHTTPS returns a handler that redirects requests to the same host,
+// path, and query using HTTPS on port, with status, which should be
+// http.StatusMovedPermanently or http.StatusPermanentRedirect. The port is
+// omitted if it is empty or 443.
+func RedirectToHTTPS(port string, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.Trim(hostOnly(r.Host), "[]")
+		switch {
+		case port != "" && port != "443":
+			host = net.JoinHostPort(host, port)
+		case strings.Contains(host, ":"):
+			host = "[" + host + "]" // IPv6
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+
+		http.Redirect(w, r, target, status)
+	})
+}
+
+// exemptACMEChallenges is middleware that calls challenges for ACME HTTP-01
+// challenges instead of calling next, so they are not redirected.
+func exemptACMEChallenges(next, challenges http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, ACMEChallengePath) {
+			challenges.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ACMEChallengeHandler returns a handler that answers ACME HTTP-01 challenges
+// with the files written to webroot by an external ACME client, e.g.,
+// certbot --webroot, which are webroot/.well-known/acme-challenge/token.
+// It responds with 404 Not Found if webroot is empty, or for a directory.
+func ACMEChallengeHandler(webroot string) http.Handler {
+	if webroot == "" {
+		return http.NotFoundHandler()
+	}
+
+	files := http.FileServer(http.Dir(webroot))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     string
+		status   int
+		target   string
+		expected int
+		location string
+	}{
+		{
+			name:     "Default port",
+			port:     "443",
+			status:   http.StatusPermanentRedirect,
+			target:   "http://example.com/path?a=1&b=2",
+			expected: http.StatusPermanentRedirect,
+			location: "https://example.com/path?a=1&b=2",
+		},
+		{
+			name:     "Moved permanently",
+			port:     "",
+			status:   http.StatusMovedPermanently,
+			target:   "http://example.com:8080/",
+			expected: http.StatusMovedPermanently,
+			location: "https://example.com/",
+		},
+		{
+			name:     "Other port",
+			port:     "8443",
+			status:   http.StatusPermanentRedirect,
+			target:   "http://example.com:8080/path",
+			expected: http.StatusPermanentRedirect,
+			location: "https://example.com:8443/path",
+		},
+		{
+			name:     "IPv6",
+			port:     "443",
+			status:   http.StatusPermanentRedirect,
+			target:   "http://[::1]:8080/path",
+			expected: http.StatusPermanentRedirect,
+			location: "https://[::1]/path",
+		},
+		{
+			name:     "IPv6 other port",
+			port:     "8443",
+			status:   http.StatusPermanentRedirect,
+			target:   "http://[::1]/path",
+			expected: http.StatusPermanentRedirect,
+			location: "https://[::1]:8443/path",
+		},
+		{
+			name:     "ACME challenge",
+			port:     "443",
+			status:   http.StatusPermanentRedirect,
+			target:   "http://example.com" + ACMEChallengePath + "token",
+			expected: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h := exemptACMEChallenges(RedirectToHTTPS(tt.port, tt.status), ACMEChallengeHandler(""))
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rr.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rr.Code)
+			}
+			if got := rr.Header().Get("Location"); got != tt.location {
+				t.Errorf("expected location %q, got %q", tt.location, got)
+			}
+		})
+	}
+}
+
+func TestACMEChallengeHandler(t *testing.T) {
+	webroot := t.TempDir()
+	dir := filepath.Join(webroot, ".well-known", "acme-challenge")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("token.thumbprint"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		webroot  string
+		target   string
+		expected int
+		body     string
+		location string
+	}{
+		{
+			name:     "Challenge",
+			webroot:  webroot,
+			target:   "http://example.com" + ACMEChallengePath + "token",
+			expected: http.StatusOK,
+			body:     "token.thumbprint",
+		},
+		{
+			name:     "Unknown challenge",
+			webroot:  webroot,
+			target:   "http://example.com" + ACMEChallengePath + "other",
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "Directory",
+			webroot:  webroot,
+			target:   "http://example.com" + ACMEChallengePath,
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "No webroot",
+			target:   "http://example.com" + ACMEChallengePath + "token",
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "Redirect",
+			webroot:  webroot,
+			target:   "http://example.com/path",
+			expected: http.StatusPermanentRedirect,
+			location: "https://example.com/path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h := exemptACMEChallenges(RedirectToHTTPS("443", http.StatusPermanentRedirect), ACMEChallengeHandler(tt.webroot))
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rr.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rr.Code)
+			}
+			if tt.body != "" && rr.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, rr.Body.String())
+			}
+			if got := rr.Header().Get("Location"); got != tt.location {
+				t.Errorf("expected location %q, got %q", tt.location, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RemotePageData holds the data for RemoteHandler.
+type RemotePageData struct {
+	Title      string       `json:"-"`                // Title of the page.
+	RemoteAddr string       `json:"remoteAddr"`       // RemoteAddr of the connection.
+	Client     *ClientInfo  `json:"client,omitempty"` // Client resolved by ProxyConfig.AddClientInfo, if used.
+	Proxy      *ProxyInfo   `json:"proxy,omitempty"`  // Proxy is the PROXY protocol header, if any.
+	Headers    []HeaderInfo `json:"headers"`          // Headers commonly set by proxies for the client.
+}
+
+// ProxyInfo holds the details of a PROXY protocol header.
+type ProxyInfo struct {
+	Version          int      `json:"version"`
+	Source           string   `json:"source,omitempty"`
+	Destination      string   `json:"destination,omitempty"`
+	AWSVPCEndpointID string   `json:"awsVpcEndpointId,omitempty"`
+	TLVs             []string `json:"tlvs,omitempty"`
+}
+
+// remoteHeaders are the headers commonly set by proxies for the client.
+var remoteHeaders = []string{
+	"Cf-Connecting-Ip",
+	"Forwarded",
+	"True-Client-Ip",
+	"X-Client-Ip",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+}
+
+// Text returns each value on a line.
+func (d RemotePageData) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "RemoteAddr: %v\n", d.RemoteAddr)
+
+	if client := d.Client; client != nil {
+		fmt.Fprintf(&b, "Client-IP: %v\n", client.IP)
+		fmt.Fprintf(&b, "Client-IP-Source: %v\n", client.Source)
+		fmt.Fprintf(&b, "Client-Scheme: %v\n", client.Scheme)
+		fmt.Fprintf(&b, "Client-Host: %v\n", client.Host)
+	}
+
+	if proxy := d.Proxy; proxy != nil {
+		fmt.Fprintf(&b, "Proxy-Protocol-Version: %v\n", proxy.Version)
+		if proxy.Source != "" {
+			fmt.Fprintf(&b, "Proxy-Source: %v\n", proxy.Source)
+		}
+		if proxy.Destination != "" {
+			fmt.Fprintf(&b, "Proxy-Destination: %v\n", proxy.Destination)
+		}
+		if proxy.AWSVPCEndpointID != "" {
+			fmt.Fprintf(&b, "Proxy-AWS-VPC-Endpoint-ID: %v\n", proxy.AWSVPCEndpointID)
+		}
+		for _, tlv := range proxy.TLVs {
+			fmt.Fprintf(&b, "Proxy-TLV: %v\n", tlv)
+		}
+	}
+
+	writeHeaderInfo(&b, d.Headers)
+
+	return b.String()
+}
+
+// RemoteHandler responds with the RemoteAddr and common headers for the actual RemoteAddr.
+// Note: RemoteAddr may not be valid if a proxy, load balancer, or similar is used to route the request.
+// If ProxyConfig.AddClientInfo is used, the resolved client is also shown.
+// The response is plain text by default. See RenderPage.
+func (h *Handler) RemoteHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+	logger.Debug("Remote Handler")
+
+	data := RemotePageData{
+		Title:      "Remote",
+		RemoteAddr: r.RemoteAddr,
+		Headers:    []HeaderInfo{},
+	}
+
+	if client, ok := ClientInfoFromContext(r.Context()); ok {
+		data.Client = &client
+	}
+
+	if header, ok := ProxyHeaderFromContext(r.Context()); ok {
+		proxy := &ProxyInfo{
+			Version:          header.Version,
+			AWSVPCEndpointID: header.AWSVPCEndpointID(),
+		}
+		if header.Source != nil {
+			proxy.Source = header.Source.String()
+		}
+		if header.Destination != nil {
+			proxy.Destination = header.Destination.String()
+		}
+		for _, tlv := range header.TLVs {
+			proxy.TLVs = append(proxy.TLVs, FormatTLV(tlv))
+		}
+		data.Proxy = proxy
+	}
+
+	for _, header := range remoteHeaders {
+		if values := r.Header.Values(header); len(values) > 0 {
+			data.Headers = append(data.Headers, HeaderInfo{Key: header, Value: values})
+		}
+	}
+
+	err := RenderPage(h.Templates(), w, r, TextPageName, data, FormatText)
+	if err != nil {
+		logger.Error("failed to RenderPage", "err", err)
+		return
+	}
+}
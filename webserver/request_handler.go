@@ -0,0 +1,93 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// RequestPageData holds the data for RequestHandler.
+type RequestPageData struct {
+	Title      string       `json:"-"`          // Title of the page.
+	Protocol   string       `json:"protocol"`   // Protocol of the connection, e.g., h2.
+	Method     string       `json:"method"`     // Method of the request.
+	URL        string       `json:"url"`        // URL is the unmodified request target.
+	Proto      string       `json:"proto"`      // Proto of the request, e.g., HTTP/1.1.
+	Host       string       `json:"host"`       // Host of the request.
+	RemoteAddr string       `json:"remoteAddr"` // RemoteAddr of the connection.
+	Headers    []HeaderInfo `json:"headers"`    // Sorted list of the request headers.
+	Body       string       `json:"body"`       // Body of the request.
+
+	dump string // dump of the request from httputil.DumpRequest.
+}
+
+// Text returns the protocol followed by the dump of the request.
+func (d RequestPageData) Text() string {
+	return fmt.Sprintf("Protocol: %s\n\n%s\n", d.Protocol, d.dump)
+}
+
+// RequestHandler dumps the HTTP request details, preceded by the protocol of
+// the connection. The response is plain text by default. See RenderPage.
+func (h *Handler) RequestHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+	logger.Debug("Request Handler")
+
+	// show request values
+	b, err := httputil.DumpRequest(r, true)
+	if err != nil {
+		http.Error(w,
+			fmt.Sprintf("Error:\n%v\n", err),
+			http.StatusInternalServerError,
+		)
+		logger.Error("DumpRequest", "err", err)
+		return
+	}
+
+	// DumpRequest restores the body, so it can be read again
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w,
+				fmt.Sprintf("Error:\n%v\n", err),
+				http.StatusInternalServerError,
+			)
+			logger.Error("ReadAll", "err", err)
+			return
+		}
+	}
+
+	data := RequestPageData{
+		Title:      "Request",
+		Protocol:   Protocol(r),
+		Method:     r.Method,
+		URL:        r.RequestURI,
+		Proto:      r.Proto,
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+		Headers:    NewHeaderInfo(r.Header),
+		Body:       strings.ToValidUTF8(string(body), "\uFFFD"),
+		dump:       string(b),
+	}
+
+	err = RenderPage(h.Templates(), w, r, TextPageName, data, FormatText)
+	if err != nil {
+		logger.Error("failed to RenderPage", "err", err)
+		return
+	}
+}
@@ -0,0 +1,116 @@
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	traceContextKey
+	clientInfoKey
+	proxyConnKey
+)
+
+const (
+	// MaxRequestIDLength is the maximum length of a trusted incoming request ID.
+	MaxRequestIDLength = 128
+
+	// DefaultRequestIDHeader is the default header for the request ID.
+	DefaultRequestIDHeader = "X-Request-ID"
+)
+
+// ValidRequestID reports whether id is acceptable as an incoming request ID.
+// It must be 1 to MaxRequestIDLength characters of letters, digits, or
+// any of "-._:+/=".
+func ValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > MaxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._:+/=", c) >= 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// RequestIDConfig configures the request ID middleware.
+type RequestIDConfig struct {
+	// TrustIncoming uses the request ID header of the request, if valid,
+	// instead of generating a new request ID. Only enable this if the
+	// header is set by a trusted proxy or load balancer.
+	TrustIncoming bool
+
+	// Generator generates new request IDs. If nil, a CounterGenerator is used.
+	Generator RequestIDGenerator
+
+	// Header is the request and response header for the request ID.
+	// If empty, DefaultRequestIDHeader is used.
+	Header string
+}
+
+// AddRequestID is middleware that adds, for each request, a unique
+// incrementing request ID to the context and headers. It uses the
+// default RequestIDConfig.
+func AddRequestID(next http.Handler) http.Handler {
+	return RequestIDConfig{}.AddRequestID(next)
+}
+
+// AddRequestID is middleware that adds, for each request, a request ID to the
+// context and headers, using the incoming request ID if c.TrustIncoming.
+// It also adds the W3C trace context from the traceparent and tracestate
+// headers, if present, to the context, which TraceTransport or
+// SetTraceContext propagate to downstream requests.
+func (c RequestIDConfig) AddRequestID(next http.Handler) http.Handler {
+	generator := c.Generator
+	if generator == nil {
+		generator = NewCounterGenerator()
+	}
+
+	header := c.Header
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestID string
+		if c.TrustIncoming {
+			if incoming := r.Header.Get(header); ValidRequestID(incoming) {
+				requestID = incoming
+			}
+		}
+		if requestID == "" {
+			requestID = generator.NewRequestID()
+		}
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+
+		if tc, err := ParseTraceContext(r.Header); err == nil {
+			ctx = context.WithValue(ctx, traceContextKey, tc)
+		}
+
+		w.Header().Set(header, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns request ID from ctx if present, otherwise "".
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	reqID, ok := ctx.Value(requestIDKey).(string)
+	if !ok {
+		return ""
+	}
+	return reqID
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RequestIDGenerator generates request IDs. It must be safe for concurrent use.
+type RequestIDGenerator interface {
+	NewRequestID() string
+}
+
+// RequestIDGenerators maps names to constructors of the built-in generators.
+var RequestIDGenerators = map[string]func() RequestIDGenerator{
+	"counter": func() RequestIDGenerator { return NewCounterGenerator() },
+	"uuidv4":  func() RequestIDGenerator { return UUIDv4Generator{} },
+	"uuidv7":  func() RequestIDGenerator { return UUIDv7Generator{} },
+	"ulid":    func() RequestIDGenerator { return ULIDGenerator{} },
+}
+
+// RequestIDGeneratorNames returns a comma-separated string of the names in
+// RequestIDGenerators.
+func RequestIDGeneratorNames() string {
+	names := make([]string, 0, len(RequestIDGenerators))
+	for name := range RequestIDGenerators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// NewRequestIDGenerator returns the built-in generator with the given name.
+// The lookup is case insensitive.
+func NewRequestIDGenerator(name string) (RequestIDGenerator, error) {
+	newGenerator, ok := RequestIDGenerators[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("invalid request ID generator: %s", name)
+	}
+	return newGenerator(), nil
+}
+
+// randomBytes fills b with random bytes. crypto/rand only fails if the
+// operating system's random source is unavailable, which is not recoverable.
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand: %v", err))
+	}
+}
+
+// randomString generates a random string of the specified length, composed of
+// uppercase letters, lowercase letters, and digits.
+func randomString(length int) (string, error) {
+	const (
+		upper  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+		lower  = "abcdefghijklmnopqrstuvwxyz"
+		digits = "0123456789"
+		chars  = upper + lower + digits
+
+		// largest multiple of len(chars) that fits in a byte, used to
+		// reject values that would bias the result
+		limit = 256 - 256%len(chars)
+	)
+
+	// check for valid length
+	if length <= 0 {
+		return "", errors.New("invalid length")
+	}
+
+	result := make([]byte, 0, length)
+	buf := make([]byte, length+length/4)
+
+	for len(result) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(result) < length {
+				result = append(result, chars[int(b)%len(chars)])
+			}
+		}
+	}
+
+	return string(result), nil
+}
+
+// CounterGenerator generates request IDs from a random prefix, which is
+// unique for each generator, and an incrementing counter.
+type CounterGenerator struct {
+	prefix  string
+	counter atomic.Uint64
+}
+
+// NewCounterGenerator returns a CounterGenerator with a new random prefix.
+func NewCounterGenerator() *CounterGenerator {
+	prefix, _ := randomString(6)
+	return &CounterGenerator{prefix: prefix}
+}
+
+// NewRequestID returns the combination of the prefix and counter.
+func (g *CounterGenerator) NewRequestID() string {
+	return fmt.Sprintf("%s%010d", g.prefix, g.counter.Add(1))
+}
+
+// UUIDv4Generator generates random version 4 UUIDs as defined by RFC 9562.
+type UUIDv4Generator struct{}
+
+// NewRequestID returns a new version 4 UUID.
+func (UUIDv4Generator) NewRequestID() string {
+	var uuid [16]byte
+	randomBytes(uuid[:])
+	return formatUUID(uuid, 4)
+}
+
+// UUIDv7Generator generates time-ordered version 7 UUIDs as defined by
+// RFC 9562, which sort by creation time with millisecond precision.
+type UUIDv7Generator struct{}
+
+// NewRequestID returns a new version 7 UUID.
+func (UUIDv7Generator) NewRequestID() string {
+	var uuid [16]byte
+	randomBytes(uuid[6:])
+
+	// 48-bit big-endian Unix timestamp in milliseconds
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(uuid[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(uuid[2:6], uint32(ms))
+
+	return formatUUID(uuid, 7)
+}
+
+// formatUUID sets the version and variant of uuid and returns it in the
+// standard 8-4-4-4-12 hexadecimal form.
+func formatUUID(uuid [16]byte, version byte) string {
+	uuid[6] = uuid[6]&0x0f | version<<4
+	uuid[8] = uuid[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+
+	return string(buf[:])
+}
+
+// ULIDGenerator generates ULIDs, which are 26 character, lexicographically
+// sortable identifiers. See https://github.com/ulid/spec.
+type ULIDGenerator struct{}
+
+// NewRequestID returns a new ULID.
+func (ULIDGenerator) NewRequestID() string {
+	// Crockford's base32 alphabet
+	const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+	// 48-bit big-endian timestamp followed by 80 random bits
+	var id [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	randomBytes(id[6:])
+
+	// encode 128 bits as 26 characters of 5 bits, with 2 leading zero bits
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	var buf [26]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = alphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(buf[:])
+}
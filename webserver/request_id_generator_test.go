@@ -0,0 +1,123 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestIDGenerators(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  *regexp.Regexp
+		sortable bool
+	}{
+		{name: "counter", pattern: regexp.MustCompile(`^[A-Za-z0-9]{6}\d{10}$`), sortable: true},
+		{name: "uuidv4", pattern: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{name: "UUIDv7", pattern: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), sortable: true},
+		{name: "ulid", pattern: regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`), sortable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewRequestIDGenerator(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const n = 100
+			ids := make([]string, 0, n)
+			seen := make(map[string]bool, n)
+			for i := 0; i < n; i++ {
+				id := g.NewRequestID()
+				if !tt.pattern.MatchString(id) {
+					t.Fatalf("expected %q to match %q", id, tt.pattern)
+				}
+				if !ValidRequestID(id) {
+					t.Errorf("expected %q to be a valid request ID", id)
+				}
+				if seen[id] {
+					t.Fatalf("duplicate request ID %q", id)
+				}
+				seen[id] = true
+				ids = append(ids, id)
+
+				// time-ordered IDs only sort across milliseconds
+				if tt.sortable && tt.name != "counter" && i%10 == 0 {
+					time.Sleep(2 * time.Millisecond)
+				}
+			}
+
+			if tt.sortable && tt.name == "counter" && !sort.StringsAreSorted(ids) {
+				t.Errorf("expected sorted IDs, got %v", ids)
+			}
+			if tt.sortable && tt.name != "counter" {
+				for i := 10; i < n; i += 10 {
+					if ids[i-10] >= ids[i] {
+						t.Errorf("expected %q < %q", ids[i-10], ids[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewRequestIDGeneratorInvalid(t *testing.T) {
+	if _, err := NewRequestIDGenerator("uuidv1"); err == nil {
+		t.Error("expected error for invalid generator")
+	}
+}
+
+func TestULIDTimestamp(t *testing.T) {
+	before := time.Now().UnixMilli()
+	id := ULIDGenerator{}.NewRequestID()
+	after := time.Now().UnixMilli()
+
+	// decode the 10 character timestamp
+	const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	var ms int64
+	for _, c := range id[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(alphabet, c))
+	}
+
+	if ms < before || ms > after {
+		t.Errorf("expected timestamp in [%d, %d], got %d", before, after, ms)
+	}
+}
+
+func TestRandomString(t *testing.T) {
+	for _, length := range []int{1, 6, 100} {
+		s, err := randomString(length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s) != length || !regexp.MustCompile(`^[A-Za-z0-9]+$`).MatchString(s) {
+			t.Errorf("unexpected random string %q for length %d", s, length)
+		}
+	}
+
+	if _, err := randomString(0); err == nil {
+		t.Error("expected error for zero length")
+	}
+}
+
+func BenchmarkRandomString(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		randomString(6)
+	}
+}
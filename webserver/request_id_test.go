@@ -0,0 +1,117 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAddRequestIDHeader(t *testing.T) {
+	config := RequestIDConfig{
+		TrustIncoming: true,
+		Generator:     UUIDv4Generator{},
+		Header:        "X-Correlation-ID",
+	}
+	h := config.AddRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "ignored")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Header().Get("X-Request-ID") != "" {
+		t.Errorf("unexpected X-Request-ID header %q", rr.Header().Get("X-Request-ID"))
+	}
+	if id := rr.Header().Get("X-Correlation-ID"); len(id) != 36 {
+		t.Errorf("expected UUID in X-Correlation-ID header, got %q", id)
+	}
+
+	req.Header.Set("X-Correlation-ID", "from-proxy")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if id := rr.Header().Get("X-Correlation-ID"); id != "from-proxy" {
+		t.Errorf("expected trusted X-Correlation-ID header 'from-proxy', got %q", id)
+	}
+}
+
+func TestAddRequestID(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        RequestIDConfig
+		incoming      string
+		expectTrusted bool
+	}{
+		{
+			name:     "Untrusted",
+			incoming: "abc-123",
+		},
+		{
+			name:          "Trusted",
+			config:        RequestIDConfig{TrustIncoming: true},
+			incoming:      "abc-123",
+			expectTrusted: true,
+		},
+		{
+			name:          "Trusted UUID",
+			config:        RequestIDConfig{TrustIncoming: true},
+			incoming:      "0b5e8f2a-3c1d-4e6f-9a7b-8c9d0e1f2a3b",
+			expectTrusted: true,
+		},
+		{
+			name:   "Trusted missing",
+			config: RequestIDConfig{TrustIncoming: true},
+		},
+		{
+			name:     "Trusted invalid charset",
+			config:   RequestIDConfig{TrustIncoming: true},
+			incoming: "abc 123<script>",
+		},
+		{
+			name:     "Trusted too long",
+			config:   RequestIDConfig{TrustIncoming: true},
+			incoming: strings.Repeat("a", MaxRequestIDLength+1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctxID string
+			h := tt.config.AddRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			headerID := rr.Header().Get("X-Request-ID")
+			if headerID != ctxID {
+				t.Errorf("expected header %q to match context %q", headerID, ctxID)
+			}
+			if (ctxID == tt.incoming) != tt.expectTrusted {
+				t.Errorf("expected trusted %v, got request ID %q for incoming %q", tt.expectTrusted, ctxID, tt.incoming)
+			}
+			if !ValidRequestID(ctxID) {
+				t.Errorf("invalid request ID %q", ctxID)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter wraps an http.ResponseWriter to record the status code and
+// number of bytes written. It supports http.Flusher, http.Hijacker, and
+// io.ReaderFrom if the wrapped ResponseWriter does.
+type ResponseWriter struct {
+	http.ResponseWriter
+
+	status   int   // status is the first non-informational status code written.
+	bytes    int64 // bytes is the number of body bytes written.
+	hijacked bool  // hijacked is set if the connection was hijacked.
+}
+
+// NewResponseWriter returns a ResponseWriter that wraps w.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// Status returns the status code written, or http.StatusOK if the header has
+// not been written explicitly. If the connection was hijacked before a status
+// was written, e.g., for a WebSocket upgrade, http.StatusSwitchingProtocols
+// is returned.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		if w.hijacked {
+			return http.StatusSwitchingProtocols
+		}
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Hijacked reports whether the connection was hijacked, after which the
+// status and bytes written by the handler are not known.
+func (w *ResponseWriter) Hijacked() bool {
+	return w.hijacked
+}
+
+// BytesWritten returns the number of body bytes written.
+func (w *ResponseWriter) BytesWritten() int64 {
+	return w.bytes
+}
+
+// WriteHeader records the status code and calls the wrapped WriteHeader.
+// Informational (1xx) status codes are passed through without being recorded.
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the number of bytes written and calls the wrapped Write.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom copies from r using the wrapped io.ReaderFrom, if available, which
+// allows optimizations such as sendfile.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		// hide ReadFrom from io.Copy to avoid recursion
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, r)
+	}
+	w.bytes += n
+	return n, err
+}
+
+// Flush calls the wrapped Flush, if available.
+func (w *ResponseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack calls the wrapped Hijack, if available, and records if it succeeds.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the wrapped ResponseWriter for use by http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writerOnly hides any methods other than Write.
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		expectedCode  int
+		expectedBytes int64
+	}{
+		{
+			name:          "Implicit status",
+			handler:       func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, "hello") },
+			expectedCode:  http.StatusOK,
+			expectedBytes: 5,
+		},
+		{
+			name:         "No body",
+			handler:      func(w http.ResponseWriter, r *http.Request) {},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Explicit status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "not found", http.StatusNotFound)
+			},
+			expectedCode:  http.StatusNotFound,
+			expectedBytes: int64(len("not found\n")),
+		},
+		{
+			name: "Informational status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusAccepted)
+			},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name: "ReadFrom",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, strings.NewReader("hello, world"))
+			},
+			expectedCode:  http.StatusOK,
+			expectedBytes: 12,
+		},
+		{
+			name: "Flush",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NewResponseController(w).Flush()
+			},
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			rw := NewResponseWriter(rr)
+
+			tt.handler(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rw.Status() != tt.expectedCode {
+				t.Errorf("expected status code %d, got %d", tt.expectedCode, rw.Status())
+			}
+			if rw.BytesWritten() != tt.expectedBytes {
+				t.Errorf("expected %d bytes, got %d", tt.expectedBytes, rw.BytesWritten())
+			}
+			if int64(rr.Body.Len()) != tt.expectedBytes {
+				t.Errorf("expected %d recorded bytes, got %d", tt.expectedBytes, rr.Body.Len())
+			}
+		})
+	}
+}
+
+func TestResponseWriterHijackNotSupported(t *testing.T) {
+	rw := NewResponseWriter(httptest.NewRecorder())
+
+	_, _, err := rw.Hijack()
+	if !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected error %v, got %v", http.ErrNotSupported, err)
+	}
+}
+
+// hijackRecorder is a ResponseRecorder that supports http.Hijacker.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (h hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.conn, bufio.NewReadWriter(bufio.NewReader(h.conn), bufio.NewWriter(h.conn)), nil
+}
+
+func TestLogRequestHijacked(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+
+	h := LogRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+		brw.Flush()
+	}))
+
+	h.ServeHTTP(hijackRecorder{httptest.NewRecorder(), server}, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log records, got %d: %s", len(lines), buf.String())
+	}
+
+	var record struct {
+		Response map[string]any
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Response["status"] != float64(http.StatusSwitchingProtocols) {
+		t.Errorf("expected status %d, got %v", http.StatusSwitchingProtocols, record.Response["status"])
+	}
+	if record.Response["hijacked"] != true {
+		t.Errorf("expected hijacked, got %v", record.Response["hijacked"])
+	}
+	if _, ok := record.Response["bytes"]; ok {
+		t.Errorf("expected no bytes, got %v", record.Response["bytes"])
+	}
+}
+
+func TestLogRequestCompletion(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	h := LogRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "short and stout")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/teapot", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log records, got %d: %s", len(lines), buf.String())
+	}
+
+	var record struct {
+		Msg     string
+		Request struct {
+			Method    string
+			URL       string
+			Proto     string
+			UserAgent string
+		}
+		Response struct {
+			Status   int
+			Bytes    int64
+			Duration int64
+		}
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Msg != "LogRequest completed" {
+		t.Errorf("expected msg 'LogRequest completed', got '%s'", record.Msg)
+	}
+	if record.Request.URL != "/teapot" || record.Request.UserAgent != "test-agent" || record.Request.Proto != "HTTP/1.1" {
+		t.Errorf("unexpected request group %+v", record.Request)
+	}
+	if record.Response.Status != http.StatusTeapot {
+		t.Errorf("expected status %d, got %d", http.StatusTeapot, record.Response.Status)
+	}
+	if record.Response.Bytes != int64(len("short and stout")) {
+		t.Errorf("expected %d bytes, got %d", len("short and stout"), record.Response.Bytes)
+	}
+}
@@ -11,7 +11,7 @@ CONDITIONS OF ANY KIND, either express or implied.  See the License for the
 specific language governing permissions and limitations under the License.
 */
 
-package main
+package webserver
 
 import (
 	"net/http"
@@ -47,7 +47,7 @@ func (h *Handler) RootHandler(w http.ResponseWriter, r *http.Request) {
 		Title: h.AppName,
 	}
 
-	err := RenderTemplate(h.Tmpl, w, RootPageName, data)
+	err := RenderTemplate(h.Templates(), w, RootPageName, data)
 	if err != nil {
 		logger.Error("unable to RenderTemplate", "err", err)
 		return
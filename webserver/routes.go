@@ -0,0 +1,139 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Route associates a URL pattern with the handler that serves it.
+type Route struct {
+	Name    string           // Name identifies the route, e.g., "hello".
+	Pattern string           // Pattern is the ServeMux pattern, e.g., "/hello".
+	Handler http.HandlerFunc // Handler serves requests that match Pattern.
+}
+
+// Routes returns the diagnostic routes provided by h.
+func (h *Handler) Routes() []Route {
+	return []Route{
+		{Name: "root", Pattern: "/", Handler: h.RootHandler},
+		{Name: "hello", Pattern: "/hello", Handler: h.HelloHandler},
+		{Name: "hellohtml", Pattern: "/hellohtml", Handler: h.HelloHTMLHandler},
+		{Name: "headers", Pattern: "/headers", Handler: h.HeadersHandler},
+		{Name: "remote", Pattern: "/remote", Handler: h.RemoteHandler},
+		{Name: "request", Pattern: "/request", Handler: h.RequestHandler},
+		{Name: "build", Pattern: "/build", Handler: h.BuildHandler},
+		{Name: "tls", Pattern: "/tls", Handler: h.TLSHandler},
+		{Name: "metrics", Pattern: "/metrics", Handler: h.MetricsHandler},
+		{Name: "get", Pattern: "/get", Handler: h.HTTPBinGetHandler},
+		{Name: "post", Pattern: "/post", Handler: h.HTTPBinBodyHandler(http.MethodPost)},
+		{Name: "put", Pattern: "/put", Handler: h.HTTPBinBodyHandler(http.MethodPut)},
+		{Name: "patch", Pattern: "/patch", Handler: h.HTTPBinBodyHandler(http.MethodPatch)},
+		{Name: "delete", Pattern: "/delete", Handler: h.HTTPBinBodyHandler(http.MethodDelete)},
+		{Name: "anything", Pattern: "/anything", Handler: h.HTTPBinAnythingHandler},
+		{Name: "anything", Pattern: "/anything/", Handler: h.HTTPBinAnythingHandler},
+		{Name: "ip", Pattern: "/ip", Handler: h.HTTPBinIPHandler},
+		{Name: "user-agent", Pattern: "/user-agent", Handler: h.HTTPBinUserAgentHandler},
+		{Name: "uuid", Pattern: "/uuid", Handler: h.HTTPBinUUIDHandler},
+		{Name: "status", Pattern: "/status/{codes}", Handler: h.StatusHandler},
+		{Name: "delay", Pattern: "/delay/{duration}", Handler: h.DelayHandler},
+		{Name: "redirect", Pattern: "/redirect/{n}", Handler: h.RedirectHandler},
+		{Name: "relative-redirect", Pattern: "/relative-redirect/{n}", Handler: h.RelativeRedirectHandler},
+		{Name: "absolute-redirect", Pattern: "/absolute-redirect/{n}", Handler: h.AbsoluteRedirectHandler},
+		{Name: "redirect-to", Pattern: "/redirect-to", Handler: h.RedirectToHandler},
+	}
+}
+
+// optInRoutes are the names of the routes that are only enabled by name,
+// since redirect-to redirects to any URL and delay holds connections open.
+var optInRoutes = []string{"delay", "redirect-to"}
+
+// DefaultRoutes returns the routes enabled if none are selected by name,
+// which exclude the routes that must be selected by name, e.g., redirect-to.
+func DefaultRoutes(routes []Route) []Route {
+	return slices.DeleteFunc(slices.Clone(routes), func(r Route) bool {
+		return slices.Contains(optInRoutes, r.Name)
+	})
+}
+
+// defaultHostRoutes are the names of the routes that report on the server,
+// rather than a host, so they are only served by the default host.
+var defaultHostRoutes = []string{"metrics"}
+
+// VirtualHostRoutes returns the routes provided by h for a virtual host,
+// which exclude the routes only served by the default host, e.g., metrics.
+func (h *Handler) VirtualHostRoutes() []Route {
+	return slices.DeleteFunc(h.Routes(), func(r Route) bool {
+		return slices.Contains(defaultHostRoutes, r.Name)
+	})
+}
+
+// RegisterRoutes adds each route to mux.
+func RegisterRoutes(mux *http.ServeMux, routes []Route) {
+	for _, route := range routes {
+		mux.HandleFunc(route.Pattern, route.Handler)
+	}
+}
+
+// NewServeMux returns a ServeMux with the default routes provided by h. See
+// DefaultRoutes.
+func (h *Handler) NewServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, DefaultRoutes(h.Routes()))
+	return mux
+}
+
+// SelectRoutes returns the routes whose names are in names, preserving the
+// order of routes. If names is empty, the DefaultRoutes are returned. An
+// error is returned if a name does not match any route.
+func SelectRoutes(routes []Route, names []string) ([]Route, error) {
+	if len(names) == 0 {
+		return DefaultRoutes(routes), nil
+	}
+
+	selected := make([]Route, 0, len(names))
+	for _, route := range routes {
+		if slices.Contains(names, route.Name) {
+			selected = append(selected, route)
+		}
+	}
+
+	for _, name := range names {
+		if !slices.ContainsFunc(routes, func(r Route) bool { return r.Name == name }) {
+			return nil, fmt.Errorf("invalid route: %s", name)
+		}
+	}
+
+	return selected, nil
+}
+
+// RouteNames returns the names of routes, without adjacent duplicates, e.g.,
+// a name with several patterns.
+func RouteNames(routes []Route) []string {
+	names := make([]string, 0, len(routes))
+	for _, route := range routes {
+		names = append(names, route.Name)
+	}
+	return slices.Compact(names)
+}
+
+// AdminRoutes returns the administrative routes provided by h, which require
+// the bearer token. See RequireToken.
+func (h *Handler) AdminRoutes(token string) []Route {
+	return []Route{
+		{Name: "loglevel", Pattern: "/admin/loglevel", Handler: RequireToken(token, http.HandlerFunc(h.LogLevelHandler)).ServeHTTP},
+	}
+}
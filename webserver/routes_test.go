@@ -0,0 +1,78 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVirtualHostRoutes(t *testing.T) {
+	h := NewHandler("test", nil)
+
+	names := RouteNames(h.Routes())
+	if !slices.Contains(names, "metrics") {
+		t.Errorf("expected metrics in %v", names)
+	}
+
+	vnames := RouteNames(h.VirtualHostRoutes())
+	if slices.Contains(vnames, "metrics") {
+		t.Errorf("expected no metrics in %v", vnames)
+	}
+	if len(vnames) != len(names)-1 {
+		t.Errorf("expected %d routes, got %d", len(names)-1, len(vnames))
+	}
+
+	// metrics is selected by name like the other routes
+	selected, err := SelectRoutes(h.Routes(), []string{"hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(RouteNames(selected), "metrics") {
+		t.Errorf("expected metrics to not be selected, got %v", RouteNames(selected))
+	}
+
+	if _, err := SelectRoutes(h.VirtualHostRoutes(), []string{"metrics"}); err == nil {
+		t.Error("expected error selecting metrics for a virtual host")
+	}
+}
+
+func TestDefaultRoutes(t *testing.T) {
+	h := NewHandler("test", nil)
+
+	names := RouteNames(DefaultRoutes(h.Routes()))
+	for _, name := range []string{"delay", "redirect-to"} {
+		if slices.Contains(names, name) {
+			t.Errorf("expected no %s in %v", name, names)
+		}
+	}
+
+	// the default routes are returned if no names are selected
+	selected, err := SelectRoutes(h.Routes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(RouteNames(selected), names) {
+		t.Errorf("expected %v, got %v", names, RouteNames(selected))
+	}
+
+	// opt-in routes are selected by name
+	selected, err = SelectRoutes(h.Routes(), []string{"hello", "redirect-to"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := RouteNames(selected); !slices.Equal(got, []string{"hello", "redirect-to"}) {
+		t.Errorf("expected [hello redirect-to], got %v", got)
+	}
+}
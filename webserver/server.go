@@ -0,0 +1,584 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ServerConfig holds configuration options for the HTTP server.
+type ServerConfig struct {
+	Addr            string        // Addr is the [host]:port to listen on, using TLS if configured, or none if empty.
+	CertFile        string        // CertFile is the default TLS certificate file.
+	KeyFile         string        // KeyFile is the default TLS private key file.
+	ReadTimeout     time.Duration // ReadTimeout is the http.Server ReadTimeout.
+	WriteTimeout    time.Duration // WriteTimeout is the http.Server WriteTimeout.
+	IdleTimeout     time.Duration // IdleTimeout is the http.Server IdleTimeout.
+	ShutdownTimeout time.Duration // ShutdownTimeout limits the graceful shutdown.
+
+	Listeners []ListenerConfig // Listeners are additional listeners.
+
+	Certificates      []CertificateFiles // Certificates are additional certificates selected by SNI.
+	CertWatchInterval time.Duration      // CertWatchInterval is how often to check for changed certificate files, or never if zero.
+
+	ACMEDomains   []string // ACMEDomains are the domains to obtain certificates for using ACME instead of CertFile and KeyFile.
+	ACMECache     string   // ACMECache is the directory to cache ACME accounts and certificates, or none if empty.
+	ACMEDirectory string   // ACMEDirectory is the ACME directory URL, or DefaultACMEDirectory if empty.
+	ACMEEmail     string   // ACMEEmail is the optional contact email for the ACME account.
+
+	RedirectAddr   string // RedirectAddr is the [host]:port to redirect HTTP to HTTPS and answer ACME HTTP-01 challenges, or none if empty.
+	RedirectStatus int    // RedirectStatus is the redirect status code, or http.StatusPermanentRedirect if zero.
+	ACMEWebroot    string // ACMEWebroot is the directory of HTTP-01 challenges from an external ACME client, answered on RedirectAddr.
+
+	// ACMEHTTPAddr is the former name of RedirectAddr, used if RedirectAddr
+	// is empty.
+	//
+	// Deprecated: Use RedirectAddr.
+	ACMEHTTPAddr string
+
+	HTTP3Addr string // HTTP3Addr is the [host]:port to serve HTTP/3 over QUIC, advertised by Alt-Svc, or none if empty.
+
+	ClientCAFile string             // ClientCAFile is the PEM file of CAs to verify client certificates.
+	ClientAuth   tls.ClientAuthType // ClientAuth is the policy for client certificates.
+
+	ProxyProtocol        bool           // ProxyProtocol reads PROXY protocol headers from connections.
+	ProxyProtocolSources []netip.Prefix // ProxyProtocolSources are the trusted sources of PROXY headers, or all if empty.
+	ProxyHeaderTimeout   time.Duration  // ProxyHeaderTimeout limits the time to read a PROXY header.
+
+	H2C                       bool          // H2C serves HTTP/2 on listeners without TLS.
+	HTTP2MaxConcurrentStreams uint32        // HTTP2MaxConcurrentStreams limits the streams per connection, or 250 if zero.
+	HTTP2MaxReadFrameSize     uint32        // HTTP2MaxReadFrameSize is the largest frame the server reads, or 1 MiB if zero.
+	HTTP2IdleTimeout          time.Duration // HTTP2IdleTimeout closes idle HTTP/2 connections, or IdleTimeout if zero.
+}
+
+// DefaultCertWatchInterval is the default interval to check for changed
+// certificate files.
+const DefaultCertWatchInterval = 10 * time.Second
+
+// DefaultServerConfig returns a ServerConfig with the default values.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Addr:               ":8080",
+		ReadTimeout:        5 * time.Second,
+		WriteTimeout:       10 * time.Second,
+		IdleTimeout:        120 * time.Second,
+		ShutdownTimeout:    10 * time.Second,
+		CertWatchInterval:  DefaultCertWatchInterval,
+		ProxyHeaderTimeout: DefaultProxyHeaderTimeout,
+	}
+}
+
+// TLS reports whether the config specifies a certificate and key, or ACME.
+func (c ServerConfig) TLS() bool {
+	return len(c.CertificateFiles()) > 0 || c.ACME()
+}
+
+// CertificateFiles returns the default certificate and key files, if any,
+// followed by Certificates.
+func (c ServerConfig) CertificateFiles() []CertificateFiles {
+	var files []CertificateFiles
+	if c.CertFile != "" && c.KeyFile != "" {
+		files = append(files, CertificateFiles{CertFile: c.CertFile, KeyFile: c.KeyFile})
+	}
+	return append(files, c.Certificates...)
+}
+
+// Server is an HTTP server that shuts down gracefully when its context ends.
+type Server struct {
+	Config     ServerConfig // Config is the configuration used to create the server.
+	HTTPServer *http.Server // HTTPServer is the underlying http.Server.
+
+	// OnReady, if not nil, is called when all of the listeners are serving.
+	OnReady func(listeners []Listener)
+
+	// OnShutdown, if not nil, is called when graceful shutdown starts.
+	OnShutdown func()
+
+	inherited []InheritedListener // inherited are used instead of opening listeners.
+
+	mu         sync.Mutex
+	listeners  []Listener     // listeners are being served, and are passed by Upgrade.
+	packetConn net.PacketConn // packetConn is the HTTP/3 connection, and is passed by Upgrade.
+	upgradeMu  sync.Mutex     // upgradeMu prevents concurrent upgrades.
+	upgraded   atomic.Bool    // upgraded is set when a new process is serving the listeners.
+
+	certs     CertificateSet                // certs is nil unless Config specifies certificates.
+	clientCAs atomic.Pointer[x509.CertPool] // clientCAs is nil unless Config specifies ClientCAFile.
+	redirect  *http.Server                  // redirect is nil unless Config specifies RedirectAddr.
+	http3     *http3.Server                 // http3 is nil unless Config specifies HTTP3Addr and TLS.
+}
+
+// NewServer creates a Server with the specified config and handler.
+func NewServer(config ServerConfig, handler http.Handler) *Server {
+	if config.RedirectAddr == "" {
+		config.RedirectAddr = config.ACMEHTTPAddr
+	}
+
+	s := &Server{
+		Config: config,
+		HTTPServer: &http.Server{
+			Addr:         config.Addr,
+			Handler:      handler,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+			IdleTimeout:  config.IdleTimeout,
+			ConnContext:  proxyConnContext,
+		},
+	}
+
+	var redirect http.Handler
+	if config.RedirectAddr != "" {
+		status := config.RedirectStatus
+		if status == 0 {
+			status = http.StatusPermanentRedirect
+		}
+		redirect = exemptACMEChallenges(RedirectToHTTPS(config.httpsPort(), status), ACMEChallengeHandler(config.ACMEWebroot))
+	}
+
+	switch {
+	case config.ACME():
+		m := newACMEManager(config)
+		s.HTTPServer.TLSConfig = m.TLSConfig()
+		if redirect != nil {
+			// the manager answers HTTP-01 challenges
+			redirect = m.HTTPHandler(redirect)
+		}
+	case config.TLS():
+		s.certs = NewCertificateSet(config.CertificateFiles()...)
+		s.HTTPServer.TLSConfig = &tls.Config{GetCertificate: s.certs.GetCertificate}
+	}
+
+	if s.HTTPServer.TLSConfig != nil {
+		s.HTTPServer.TLSConfig.ClientAuth = config.ClientAuth
+	}
+
+	if s.HTTPServer.TLSConfig != nil && config.HTTP3Addr != "" {
+		s.http3 = newHTTP3Server(config, handler)
+		if port, err := config.http3Port(); err == nil {
+			s.HTTPServer.Handler = AdvertiseHTTP3(port, s.HTTPServer.Handler)
+		}
+	}
+
+	if redirect != nil {
+		s.redirect = &http.Server{
+			Addr:         config.RedirectAddr,
+			Handler:      LogRequest(redirect),
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+			IdleTimeout:  config.IdleTimeout,
+		}
+	}
+
+	return s
+}
+
+// httpsPort returns the port of Addr, or if empty, of the first TCP listener
+// using TLS, or "" if there is no port.
+func (c ServerConfig) httpsPort() string {
+	addr := c.Addr
+	if addr == "" {
+		for _, l := range c.Listeners {
+			if l.Network == "tcp" && l.TLS {
+				addr = l.Addr
+				break
+			}
+		}
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	return port
+}
+
+// ReloadCertificate reloads the TLS certificate and key files, and the client
+// CAs once the server is serving. If an error occurs, the current certificate
+// or client CAs continue to be used. It does nothing if the server does not
+// use certificate files or client CAs.
+func (s *Server) ReloadCertificate() error {
+	var errs []error
+	if s.certs != nil {
+		errs = append(errs, s.certs.Reload())
+	}
+	if s.clientCAs.Load() != nil {
+		errs = append(errs, s.reloadClientCAs())
+	}
+	return errors.Join(errs...)
+}
+
+// reloadClientCAs loads Config.ClientCAFile for new connections.
+func (s *Server) reloadClientCAs() error {
+	pool, err := LoadCertPool(s.Config.ClientCAFile)
+	if err != nil {
+		return fmt.Errorf("failed to load client CAs: %w", err)
+	}
+	s.clientCAs.Store(pool)
+	return nil
+}
+
+// getConfigForClient returns the TLS config with the current client CAs, so
+// they can be reloaded after the http.Server uses the config. A client
+// certificate is not requested for ACME TLS-ALPN-01 challenges, since the
+// ACME CA does not have one.
+func (s *Server) getConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	config := s.HTTPServer.TLSConfig.Clone()
+	config.GetConfigForClient = nil
+	config.ClientCAs = s.clientCAs.Load()
+	if s.Config.ACME() && isACMEChallenge(hello) {
+		config.ClientAuth = tls.NoClientCert
+	}
+	return config, nil
+}
+
+// Certificates returns the TLS certificates, or nil if the server does not
+// use certificate files.
+func (s *Server) Certificates() CertificateSet {
+	return s.certs
+}
+
+// AdminRoutes returns the administrative routes provided by s, which require
+// the bearer token. See RequireToken.
+func (s *Server) AdminRoutes(token string) []Route {
+	if s.certs == nil {
+		return nil
+	}
+
+	return []Route{
+		{Name: "certificate", Pattern: "/admin/certificate", Handler: RequireToken(token, http.HandlerFunc(s.certs.CertificateHandler)).ServeHTTP},
+	}
+}
+
+// Listener is a listener and how to serve it.
+type Listener struct {
+	net.Listener
+
+	TLS      bool // TLS serves HTTPS on the listener.
+	Redirect bool // Redirect serves redirects to HTTPS on the listener instead of the handler.
+
+	unlink bool // unlink is set if closing the listener removes its Unix domain socket file.
+}
+
+// Inherit sets listeners inherited from the parent process, such as by
+// SystemdListeners, to be used by Listen instead of opening listeners.
+func (s *Server) Inherit(listeners []InheritedListener) {
+	s.inherited = listeners
+}
+
+// listenerSpec is a configured listener and whether it serves redirects.
+type listenerSpec struct {
+	ListenerConfig
+	redirect bool
+}
+
+// listenerSpecs returns the listeners for Config.Addr, Config.Listeners, and
+// Config.RedirectAddr.
+func (s *Server) listenerSpecs() []listenerSpec {
+	var specs []listenerSpec
+	if s.Config.Addr != "" {
+		specs = append(specs, listenerSpec{ListenerConfig: ListenerConfig{Network: "tcp", Addr: s.Config.Addr, TLS: s.HTTPServer.TLSConfig != nil}})
+	}
+	for _, config := range s.Config.Listeners {
+		specs = append(specs, listenerSpec{ListenerConfig: config})
+	}
+	if s.redirect != nil {
+		specs = append(specs, listenerSpec{ListenerConfig: ListenerConfig{Network: "tcp", Addr: s.redirect.Addr}, redirect: true})
+	}
+	return specs
+}
+
+// Listen opens the listeners for Config.Addr, Config.Listeners, and
+// Config.RedirectAddr. If an error occurs, any opened listeners are closed.
+//
+// If listeners were inherited, only those are used. Each is served like the
+// configured listener with the same address, or otherwise by its name:
+// "https" or "tls" for TLS, "redirect" for redirects, and anything else
+// without TLS.
+func (s *Server) Listen() ([]Listener, error) {
+	specs := s.listenerSpecs()
+
+	if len(s.inherited) > 0 {
+		return s.adoptInherited(specs), nil
+	}
+
+	var listeners []Listener
+	for _, spec := range specs {
+		ln, err := spec.Listen()
+		if err != nil {
+			closeListeners(listeners)
+			return nil, fmt.Errorf("failed to listen: %w", err)
+		}
+		listeners = append(listeners, Listener{Listener: ln, TLS: spec.TLS, Redirect: spec.redirect, unlink: spec.Network == "unix"})
+	}
+
+	if len(listeners) == 0 {
+		return nil, errors.New("no listeners configured")
+	}
+
+	return listeners, nil
+}
+
+// adoptInherited returns the inherited listeners configured by the matching
+// spec or by name.
+func (s *Server) adoptInherited(specs []listenerSpec) []Listener {
+	listeners := make([]Listener, 0, len(s.inherited))
+	for _, inherited := range s.inherited {
+		// a datagram socket can only be used for HTTP/3
+		if inherited.PacketConn != nil {
+			s.mu.Lock()
+			if s.http3 != nil && s.packetConn == nil {
+				s.packetConn = inherited.PacketConn
+			} else {
+				inherited.PacketConn.Close()
+			}
+			s.mu.Unlock()
+			continue
+		}
+
+		l := Listener{Listener: inherited.Listener, unlink: inherited.unlink}
+
+		i := slices.IndexFunc(specs, func(spec listenerSpec) bool {
+			return spec.matches(inherited.Addr())
+		})
+		if i >= 0 {
+			l.TLS, l.Redirect = specs[i].TLS, specs[i].redirect
+		} else {
+			switch inherited.Name {
+			case "https", "tls":
+				l.TLS = s.HTTPServer.TLSConfig != nil
+			case "redirect":
+				l.Redirect = s.redirect != nil
+			}
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	s.inherited = nil
+
+	return listeners
+}
+
+// matches reports whether addr is the address of the listener for c.
+func (c ListenerConfig) matches(addr net.Addr) bool {
+	if addr.Network() != c.Network {
+		return false
+	}
+
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return addr.String() == c.Addr
+	}
+
+	host, port, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return false
+	}
+	if p, err := net.LookupPort("tcp", port); err != nil || p != tcpAddr.Port {
+		return false
+	}
+
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		return tcpAddr.IP.IsUnspecified()
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(ips, tcpAddr.IP.Equal)
+}
+
+// closeListeners closes each of listeners.
+func closeListeners(listeners []Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
+
+// Run opens the configured listeners and serves requests until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	listeners, err := s.Listen()
+	if err != nil {
+		return err
+	}
+
+	return s.ServeListeners(ctx, listeners)
+}
+
+// Serve accepts connections on ln, using TLS if configured, and serves
+// requests until ctx is done. See ServeListeners.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	return s.ServeListeners(ctx, []Listener{{Listener: ln, TLS: s.HTTPServer.TLSConfig != nil}})
+}
+
+// ServeListeners accepts connections on each of listeners and serves requests
+// until ctx is done or a listener fails, then gracefully shuts down all of
+// the listeners within Config.ShutdownTimeout. ServeListeners always closes
+// the listeners.
+func (s *Server) ServeListeners(ctx context.Context, listeners []Listener) error {
+	if err := s.prepareTLS(ctx, listeners); err != nil {
+		closeListeners(listeners)
+		return err
+	}
+
+	if err := s.configureHTTP2(); err != nil {
+		closeListeners(listeners)
+		return fmt.Errorf("failed to configure HTTP/2: %w", err)
+	}
+
+	s.mu.Lock()
+	s.listeners = listeners
+	s.mu.Unlock()
+
+	errChan := make(chan error, len(listeners)+1)
+
+	for _, l := range listeners {
+		ln := l.Listener
+
+		// PROXY headers do not apply to Unix domain sockets
+		if s.Config.ProxyProtocol && !l.Redirect && ln.Addr().Network() == "tcp" {
+			ln = &ProxyProtocolListener{
+				Listener:       ln,
+				TrustedSources: s.Config.ProxyProtocolSources,
+				HeaderTimeout:  s.Config.ProxyHeaderTimeout,
+			}
+		}
+
+		go func(l Listener, ln net.Listener) {
+			switch {
+			case l.Redirect:
+				errChan <- s.redirect.Serve(ln)
+			case l.TLS:
+				// certificates are provided by TLSConfig.GetCertificate
+				errChan <- s.HTTPServer.ServeTLS(ln, "", "")
+			default:
+				errChan <- s.HTTPServer.Serve(ln)
+			}
+		}(l, ln)
+
+		slog.Info("started server",
+			slog.String("network", ln.Addr().Network()),
+			slog.String("addr", ln.Addr().String()),
+			slog.Bool("tls", l.TLS),
+			slog.Bool("redirect", l.Redirect),
+		)
+	}
+
+	if s.http3 != nil {
+		pc, err := s.listenHTTP3()
+		if err != nil {
+			closeListeners(listeners)
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+
+		// share the TLS configuration, including the certificates and client CAs
+		s.http3.TLSConfig = s.HTTPServer.TLSConfig
+		go func() { errChan <- s.http3.Serve(pc) }()
+
+		slog.Info("started server",
+			slog.String("network", pc.LocalAddr().Network()),
+			slog.String("addr", pc.LocalAddr().String()),
+			slog.Bool("tls", true),
+			slog.String("protocol", ProtocolHTTP3),
+		)
+	}
+
+	if s.OnReady != nil {
+		s.OnReady(listeners)
+	}
+
+	var serveErr error
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, http.ErrServerClosed) {
+			serveErr = fmt.Errorf("failed to serve: %w", err)
+		}
+	case <-ctx.Done():
+		slog.Info("shutting down server", "cause", context.Cause(ctx))
+	}
+
+	if s.OnShutdown != nil {
+		s.OnShutdown()
+	}
+
+	// ctx may be done, so derive the shutdown deadline from a fresh context
+	timeoutCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.Config.ShutdownTimeout)
+	defer cancel()
+
+	err := s.HTTPServer.Shutdown(timeoutCtx)
+	if s.redirect != nil {
+		err = errors.Join(err, s.redirect.Shutdown(timeoutCtx))
+	}
+	err = errors.Join(err, s.shutdownHTTP3(timeoutCtx))
+	if serveErr != nil {
+		return serveErr
+	}
+	if err != nil {
+		return fmt.Errorf("server shutdown error: %w", err)
+	}
+
+	slog.Info("server shutdown")
+
+	return nil
+}
+
+// prepareTLS loads the certificates and client CAs, if configured, and
+// starts watching the certificates. An error is returned if a listener uses
+// TLS but TLS is not configured.
+func (s *Server) prepareTLS(ctx context.Context, listeners []Listener) error {
+	for _, l := range listeners {
+		if l.TLS && s.HTTPServer.TLSConfig == nil {
+			return fmt.Errorf("TLS is not configured for %s", l.Addr())
+		}
+	}
+
+	if s.certs != nil {
+		if err := s.certs.Reload(); err != nil {
+			return fmt.Errorf("failed to load certificate: %w", err)
+		}
+
+		if s.Config.CertWatchInterval > 0 {
+			s.certs.Watch(ctx, s.Config.CertWatchInterval)
+		}
+	}
+
+	if s.HTTPServer.TLSConfig == nil {
+		return nil
+	}
+
+	if s.Config.ClientCAFile != "" {
+		if err := s.reloadClientCAs(); err != nil {
+			return err
+		}
+	}
+	if s.Config.ClientCAFile != "" || (s.Config.ACME() && s.Config.ClientAuth != tls.NoClientCert) {
+		s.HTTPServer.TLSConfig.GetConfigForClient = s.getConfigForClient
+	}
+
+	return nil
+}
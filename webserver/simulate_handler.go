@@ -0,0 +1,281 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxDelay is the longest delay of DelayHandler.
+const MaxDelay = 10 * time.Second
+
+// delayWriteMargin is the time after the delay of DelayHandler to write the
+// response, which replaces the server WriteTimeout.
+const delayWriteMargin = 10 * time.Second
+
+// simulateMethods are the methods allowed by the simulation handlers.
+var simulateMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// MaxStatusWeight is the largest weight of a status code of StatusHandler.
+const MaxStatusWeight = 1000
+
+// weightedStatus is a status code and its relative likelihood.
+type weightedStatus struct {
+	code   int
+	weight int
+}
+
+// parseStatusCodes parses comma-separated status codes, each with an optional
+// weight after a colon, e.g., "200:3,503:1". The weight defaults to 1 and is
+// at most MaxStatusWeight.
+func parseStatusCodes(s string) ([]weightedStatus, error) {
+	var codes []weightedStatus
+	total := 0
+	for _, field := range strings.Split(s, ",") {
+		codeStr, weightStr, hasWeight := strings.Cut(strings.TrimSpace(field), ":")
+
+		code, err := strconv.Atoi(codeStr)
+		if err != nil || code < 200 || code > 599 {
+			return nil, fmt.Errorf("invalid status code %q (valid: 200 to 599)", codeStr)
+		}
+
+		weight := 1
+		if hasWeight {
+			weight, err = strconv.Atoi(weightStr)
+			if err != nil || weight < 1 || weight > MaxStatusWeight {
+				return nil, fmt.Errorf("invalid weight %q for status code %d (valid: 1 to %d)", weightStr, code, MaxStatusWeight)
+			}
+		}
+
+		codes = append(codes, weightedStatus{code: code, weight: weight})
+		total += weight
+	}
+
+	// the total is the argument to rand.IntN, which must be positive
+	if total <= 0 {
+		return nil, fmt.Errorf("invalid total weight %d", total)
+	}
+	return codes, nil
+}
+
+// chooseStatus returns a random status code of codes by weight.
+func chooseStatus(codes []weightedStatus) int {
+	total := 0
+	for _, c := range codes {
+		total += c.weight
+	}
+
+	n := rand.IntN(total)
+	for _, c := range codes {
+		if n < c.weight {
+			return c.code
+		}
+		n -= c.weight
+	}
+	return codes[len(codes)-1].code
+}
+
+// StatusHandler responds with a status code chosen from the codes in the
+// path, e.g., /status/503 or /status/200:3,503:1 to respond with 200 three
+// times as often as 503.
+func (h *Handler) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, simulateMethods...) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	codes, err := parseStatusCodes(r.PathValue("codes"))
+	if err != nil {
+		logger.Error("invalid status codes", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(chooseStatus(codes))
+}
+
+// parseDelay parses s as seconds, e.g., 2 or 0.5, or a duration, e.g., 500ms,
+// up to MaxDelay.
+func parseDelay(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		// compare seconds first to avoid overflow
+		switch {
+		case !(seconds >= 0):
+			return 0, fmt.Errorf("invalid delay %q", s)
+		case seconds >= MaxDelay.Seconds():
+			return MaxDelay, nil
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid delay %q", s)
+	}
+	return min(d, MaxDelay), nil
+}
+
+// DelayHandler responds after the delay in the path, in seconds or as a
+// duration, e.g., /delay/2 or /delay/500ms, up to MaxDelay, with the request
+// details of HTTPBinBodyHandler. It stops waiting if the request is canceled,
+// e.g., the client disconnects.
+func (h *Handler) DelayHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, simulateMethods...) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	delay, err := parseDelay(r.PathValue("duration"))
+	if err != nil {
+		logger.Error("invalid delay", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := newHTTPBinBody(w, r)
+	if err != nil {
+		logger.Error("failed to read body", "err", err)
+		writeHTTPBinError(w, err)
+		return
+	}
+
+	// extend the write deadline, which may be before the delay ends
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(delay + delayWriteMargin)); err != nil {
+		logger.Warn("failed to SetWriteDeadline", "err", err)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-r.Context().Done():
+		logger.Info("delay canceled", "delay", delay, "err", r.Context().Err())
+		return
+	case <-timer.C:
+	}
+
+	if err := RenderJSON(w, data); err != nil {
+		logger.Error("failed to RenderJSON", "err", err)
+	}
+}
+
+// redirectN redirects to the next of n redirects in the path, ending at /get.
+// The Location is an absolute URL if absolute is true, otherwise a path.
+func redirectN(w http.ResponseWriter, r *http.Request, absolute bool) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, simulateMethods...) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil || n < 1 {
+		logger.Error("invalid number of redirects", "n", r.PathValue("n"))
+		http.Error(w, fmt.Sprintf("invalid number of redirects %q", r.PathValue("n")), http.StatusBadRequest)
+		return
+	}
+
+	location := "/get"
+	switch {
+	case n > 1 && absolute:
+		location = fmt.Sprintf("/absolute-redirect/%d", n-1)
+	case n > 1:
+		location = fmt.Sprintf("/relative-redirect/%d", n-1)
+	}
+	if absolute {
+		client := requestClient(r)
+		location = client.Scheme + "://" + client.Host + location
+	}
+
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusFound)
+}
+
+// RedirectHandler redirects n times, as given by the path, e.g., /redirect/3,
+// before responding with /get. The redirects are relative paths, or absolute
+// URLs if the absolute query parameter is true.
+func (h *Handler) RedirectHandler(w http.ResponseWriter, r *http.Request) {
+	redirectN(w, r, r.URL.Query().Get("absolute") == "true")
+}
+
+// RelativeRedirectHandler redirects n times, as given by the path, using
+// relative paths, before responding with /get.
+func (h *Handler) RelativeRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	redirectN(w, r, false)
+}
+
+// AbsoluteRedirectHandler redirects n times, as given by the path, using
+// absolute URLs, before responding with /get.
+func (h *Handler) AbsoluteRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	redirectN(w, r, true)
+}
+
+// RedirectToHandler redirects to the url query parameter with the status
+// query parameter, 302 Found by default, which must be 3xx.
+func (h *Handler) RedirectToHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+
+	if !ValidMethod(w, r, simulateMethods...) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	query := r.URL.Query()
+
+	location := query.Get("url")
+	if location == "" {
+		logger.Error("missing url")
+		http.Error(w, "missing url", http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusFound
+	if s := query.Get("status"); s != "" {
+		var err error
+		status, err = strconv.Atoi(s)
+		if err != nil || status < 300 || status > 399 {
+			logger.Error("invalid status", "status", s)
+			http.Error(w, fmt.Sprintf("invalid status %q (valid: 300 to 399)", s), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Location", location)
+	w.WriteHeader(status)
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		method         string
+		target         string
+		expectedStatus []int
+	}{
+		{
+			name:           "Single code",
+			method:         http.MethodGet,
+			target:         "/status/503",
+			expectedStatus: []int{http.StatusServiceUnavailable},
+		},
+		{
+			name:           "POST request",
+			method:         http.MethodPost,
+			target:         "/status/201",
+			expectedStatus: []int{http.StatusCreated},
+		},
+		{
+			name:           "Weighted codes",
+			method:         http.MethodGet,
+			target:         "/status/200:3,503:1",
+			expectedStatus: []int{http.StatusOK, http.StatusServiceUnavailable},
+		},
+		{
+			name:           "Zero weight",
+			method:         http.MethodGet,
+			target:         "/status/200:0",
+			expectedStatus: []int{http.StatusBadRequest},
+		},
+		{
+			name:           "Overflowing weight",
+			method:         http.MethodGet,
+			target:         "/status/200:9223372036854775807,503:1",
+			expectedStatus: []int{http.StatusBadRequest},
+		},
+		{
+			name:           "Weight too large",
+			method:         http.MethodGet,
+			target:         "/status/200:1001",
+			expectedStatus: []int{http.StatusBadRequest},
+		},
+		{
+			name:           "Maximum weight",
+			method:         http.MethodGet,
+			target:         "/status/204:1000",
+			expectedStatus: []int{http.StatusNoContent},
+		},
+		{
+			name:           "Invalid code",
+			method:         http.MethodGet,
+			target:         "/status/abc",
+			expectedStatus: []int{http.StatusBadRequest},
+		},
+		{
+			name:           "Informational code",
+			method:         http.MethodGet,
+			target:         "/status/100",
+			expectedStatus: []int{http.StatusBadRequest},
+		},
+		{
+			name:           "Invalid method",
+			method:         http.MethodConnect,
+			target:         "/status/200",
+			expectedStatus: []int{http.StatusMethodNotAllowed},
+		},
+	}
+
+	mux := handler.NewServeMux()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			for _, status := range tc.expectedStatus {
+				if rr.Code == status {
+					return
+				}
+			}
+			t.Errorf("expected status code in %v, got %d", tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestChooseStatus(t *testing.T) {
+	codes, err := parseStatusCodes("200:3,503:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[int]int{}
+	for range 4000 {
+		counts[chooseStatus(codes)]++
+	}
+
+	// 200 is chosen about three times as often as 503
+	if counts[200] < 2700 || counts[200] > 3300 || counts[200]+counts[503] != 4000 {
+		t.Errorf("expected about 3000 of 200 and 1000 of 503, got %v", counts)
+	}
+}
+
+func TestParseDelay(t *testing.T) {
+	testCases := []struct {
+		delay       string
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{delay: "2", expected: 2 * time.Second},
+		{delay: "0.5", expected: 500 * time.Millisecond},
+		{delay: "250ms", expected: 250 * time.Millisecond},
+		{delay: "60", expected: MaxDelay},
+		{delay: "1e300", expected: MaxDelay},
+		{delay: "1h", expected: MaxDelay},
+		{delay: "-1", expectedErr: true},
+		{delay: "-1s", expectedErr: true},
+		{delay: "NaN", expectedErr: true},
+		{delay: "soon", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.delay, func(t *testing.T) {
+			got, err := parseDelay(tc.delay)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDelayHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		target         string
+		cancel         bool
+		expectedStatus int
+		expectedBody   string
+		minDuration    time.Duration
+	}{
+		{
+			name:           "Delay",
+			target:         "/delay/100ms",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"url": "http://example.com/delay/100ms"`,
+			minDuration:    100 * time.Millisecond,
+		},
+		{
+			name:           "Canceled",
+			target:         "/delay/10",
+			cancel:         true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name:           "Invalid delay",
+			target:         "/delay/soon",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `invalid delay "soon"`,
+		},
+	}
+
+	// delay is only enabled by name
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, handler.Routes())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				time.AfterFunc(50*time.Millisecond, cancel)
+			}
+
+			req := httptest.NewRequestWithContext(ctx, http.MethodGet, tc.target, nil)
+			rr := httptest.NewRecorder()
+
+			start := time.Now()
+			mux.ServeHTTP(rr, req)
+			elapsed := time.Since(start)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("expected status code %d, got %d", tc.expectedStatus, rr.Code)
+			}
+			if elapsed < tc.minDuration || elapsed > 5*time.Second {
+				t.Errorf("expected delay of at least %v, got %v", tc.minDuration, elapsed)
+			}
+
+			body := rr.Body.String()
+			if tc.expectedBody == "" && body != "" || !strings.Contains(body, tc.expectedBody) {
+				t.Errorf("expected response body '%s', got '%s'", tc.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestRedirectHandlers(t *testing.T) {
+	testCases := []struct {
+		name             string
+		method           string
+		target           string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "Redirect",
+			method:           http.MethodGet,
+			target:           "/redirect/3",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "/relative-redirect/2",
+		},
+		{
+			name:             "Redirect absolute",
+			method:           http.MethodGet,
+			target:           "/redirect/3?absolute=true",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "http://example.com/absolute-redirect/2",
+		},
+		{
+			name:             "Last redirect",
+			method:           http.MethodGet,
+			target:           "/redirect/1",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "/get",
+		},
+		{
+			name:             "Relative redirect",
+			method:           http.MethodGet,
+			target:           "/relative-redirect/2",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "/relative-redirect/1",
+		},
+		{
+			name:             "Absolute redirect",
+			method:           http.MethodGet,
+			target:           "/absolute-redirect/2",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "http://example.com/absolute-redirect/1",
+		},
+		{
+			name:             "Last absolute redirect",
+			method:           http.MethodGet,
+			target:           "/absolute-redirect/1",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "http://example.com/get",
+		},
+		{
+			name:           "Zero redirects",
+			method:         http.MethodGet,
+			target:         "/redirect/0",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid redirects",
+			method:         http.MethodGet,
+			target:         "/relative-redirect/many",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:             "Redirect to",
+			method:           http.MethodGet,
+			target:           "/redirect-to?url=https%3A%2F%2Fexample.org%2F",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.org/",
+		},
+		{
+			name:             "Redirect to with status",
+			method:           http.MethodPost,
+			target:           "/redirect-to?url=/get&status=307",
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "/get",
+		},
+		{
+			name:           "Redirect to without url",
+			method:         http.MethodGet,
+			target:         "/redirect-to",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Redirect to with invalid status",
+			method:         http.MethodGet,
+			target:         "/redirect-to?url=/get&status=200",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid method",
+			method:         http.MethodConnect,
+			target:         "/redirect/1",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	// redirect-to is only enabled by name
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, handler.Routes())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("expected status code %d, got %d", tc.expectedStatus, rr.Code)
+			}
+
+			location := rr.Header().Get("Location")
+			if location != tc.expectedLocation {
+				t.Errorf("expected Location header '%s', got '%s'", tc.expectedLocation, location)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// InheritedListener is a listener inherited from the parent process, such as
+// systemd with socket activation. For a datagram socket, Listener is nil and
+// PacketConn is set.
+type InheritedListener struct {
+	net.Listener
+
+	PacketConn net.PacketConn // PacketConn is the datagram socket, e.g., for HTTP/3.
+	Name       string         // Name is the name of the file descriptor, e.g., FileDescriptorName in a systemd socket unit.
+
+	unlink bool // unlink is set if closing the listener removes its Unix domain socket file.
+}
+
+// SystemdListeners returns the listeners passed by systemd socket activation
+// using LISTEN_PID, LISTEN_FDS, and LISTEN_FDNAMES, or nil if none were
+// passed. The environment variables are unset so child processes do not
+// inherit them.
+func SystemdListeners() ([]InheritedListener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	return inheritListeners(listenFDsStart, n, strings.Split(os.Getenv("LISTEN_FDNAMES"), ":"))
+}
+
+// inheritListeners returns the listeners for the n file descriptors starting
+// at start, named by names.
+func inheritListeners(start, n int, names []string) ([]InheritedListener, error) {
+	listeners := make([]InheritedListener, 0, n)
+	for i := 0; i < n; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(start+i), name)
+		// FileListener and FilePacketConn duplicate the file descriptor, so
+		// close the original
+		inherited := InheritedListener{Name: name}
+		ln, err := net.FileListener(f)
+		if err == nil {
+			inherited.Listener = ln
+		} else if pc, pcErr := net.FilePacketConn(f); pcErr == nil {
+			inherited.PacketConn, err = pc, nil
+		}
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.close()
+			}
+			return nil, fmt.Errorf("inherited file descriptor %d: %w", start+i, err)
+		}
+
+		listeners = append(listeners, inherited)
+	}
+
+	return listeners, nil
+}
+
+// close closes the listener or packet connection of l.
+func (l InheritedListener) close() error {
+	if l.PacketConn != nil {
+		return l.PacketConn.Close()
+	}
+	return l.Listener.Close()
+}
+
+// SdNotify sends state, such as "READY=1", to the service manager using
+// NOTIFY_SOCKET. It reports whether the notification was sent, which is
+// false without an error if NOTIFY_SOCKET is not set.
+func SdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// a leading @ is an abstract socket, which net supports
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("SdNotify: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("SdNotify: %w", err)
+	}
+
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog timeout of the service manager from
+// WATCHDOG_USEC and WATCHDOG_PID, or zero if the watchdog is not enabled for
+// this process.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog sends "WATCHDOG=1" to the service manager at half of the
+// WatchdogInterval until ctx is done. It returns immediately if the watchdog
+// is not enabled.
+func RunWatchdog(ctx context.Context) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := SdNotify("WATCHDOG=1"); err != nil {
+				slog.Warn("failed to notify watchdog", "err", err)
+			}
+		}
+	}
+}
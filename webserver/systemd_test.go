@@ -0,0 +1,215 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := SdNotify("READY=1"); sent || err != nil {
+		t.Errorf("expected not sent without NOTIFY_SOCKET, got %v, %v", sent, err)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	if sent, err := SdNotify("READY=1\nSTATUS=serving"); !sent || err != nil {
+		t.Fatalf("expected sent, got %v, %v", sent, err)
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "READY=1\nSTATUS=serving" {
+		t.Errorf("expected state, got %q", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name     string
+		usec     string
+		pid      string
+		expected time.Duration
+	}{
+		{name: "Disabled", usec: "", pid: "", expected: 0},
+		{name: "Enabled", usec: "30000000", pid: "", expected: 30 * time.Second},
+		{name: "This process", usec: "30000000", pid: pid, expected: 30 * time.Second},
+		{name: "Other process", usec: "30000000", pid: "1", expected: 0},
+		{name: "Invalid", usec: "soon", pid: "", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+
+			if got := WatchdogInterval(); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// dupListenerFD returns a duplicate file descriptor for the listener or packet
+// connection socket, which is closed by inheritListeners, as if passed by the
+// parent process.
+func dupListenerFD(t *testing.T, socket any) int {
+	t.Helper()
+
+	f, err := socketFile(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fd
+}
+
+// inheritTCP returns a listener on a random port inherited using a
+// duplicate of its file descriptor, as if passed by systemd.
+func inheritTCP(t *testing.T, name string) InheritedListener {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	inherited, err := inheritListeners(dupListenerFD(t, ln), 1, []string{name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { inherited[0].Close() })
+
+	return inherited[0]
+}
+
+func TestServerInherit(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "server")
+
+	matched := inheritTCP(t, "")
+	https := inheritTCP(t, "https")
+	redirect := inheritTCP(t, "redirect")
+	other := inheritTCP(t, "other")
+
+	if matched.Name != "" || https.Name != "https" {
+		t.Errorf("unexpected names %q and %q", matched.Name, https.Name)
+	}
+
+	config := DefaultServerConfig()
+	config.Addr = matched.Addr().String()
+	config.CertFile = certFile
+	config.KeyFile = keyFile
+	config.RedirectAddr = ":80"
+	srv := NewServer(config, handler.NewServeMux())
+	srv.Inherit([]InheritedListener{matched, https, redirect, other})
+
+	listeners, err := srv.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Listener{
+		{Listener: matched, TLS: true},
+		{Listener: https, TLS: true},
+		{Listener: redirect, Redirect: true},
+		{Listener: other},
+	}
+	if len(listeners) != len(expected) {
+		t.Fatalf("expected %d listeners, got %d", len(expected), len(listeners))
+	}
+	for i, l := range listeners {
+		if l.Addr().String() != expected[i].Addr().String() || l.TLS != expected[i].TLS || l.Redirect != expected[i].Redirect {
+			t.Errorf("listener %d: expected %+v, got %+v", i, expected[i], l)
+		}
+	}
+}
+
+func TestListenerConfigMatches(t *testing.T) {
+	tcp := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}
+	any6 := &net.TCPAddr{IP: net.IPv6unspecified, Port: 443}
+	unix := &net.UnixAddr{Name: "/run/go-webserver.sock", Net: "unix"}
+
+	tests := []struct {
+		name     string
+		config   ListenerConfig
+		addr     net.Addr
+		expected bool
+	}{
+		{name: "TCP", config: ListenerConfig{Network: "tcp", Addr: "127.0.0.1:8080"}, addr: tcp, expected: true},
+		{name: "TCP other port", config: ListenerConfig{Network: "tcp", Addr: "127.0.0.1:8081"}, addr: tcp},
+		{name: "TCP other IP", config: ListenerConfig{Network: "tcp", Addr: "10.0.0.1:8080"}, addr: tcp},
+		{name: "TCP all", config: ListenerConfig{Network: "tcp", Addr: ":443"}, addr: any6, expected: true},
+		{name: "TCP IPv4 all", config: ListenerConfig{Network: "tcp", Addr: "0.0.0.0:443"}, addr: any6, expected: true},
+		{name: "TCP named port", config: ListenerConfig{Network: "tcp", Addr: ":https"}, addr: any6, expected: true},
+		{name: "Unix", config: ListenerConfig{Network: "unix", Addr: "/run/go-webserver.sock"}, addr: unix, expected: true},
+		{name: "Unix other path", config: ListenerConfig{Network: "unix", Addr: "/run/other.sock"}, addr: unix},
+		{name: "Network", config: ListenerConfig{Network: "unix", Addr: "127.0.0.1:8080"}, addr: tcp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.matches(tt.addr); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInheritListenersPacketConn(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	inherited, err := inheritListeners(dupListenerFD(t, pc), 1, []string{ProtocolHTTP3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inherited[0].close()
+
+	if inherited[0].Listener != nil || inherited[0].PacketConn == nil {
+		t.Fatalf("expected only a packet connection, got %+v", inherited[0])
+	}
+	if got := inherited[0].PacketConn.LocalAddr().String(); got != pc.LocalAddr().String() {
+		t.Errorf("expected addr %s, got %s", pc.LocalAddr(), got)
+	}
+}
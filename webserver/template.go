@@ -0,0 +1,197 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// templateFS holds the default templates compiled into the package.
+//
+//go:embed html/*.html
+var templateFS embed.FS
+
+// InitTemplates parses the templates.
+func InitTemplates(pattern string) (*template.Template, error) {
+	tmpls, err := template.New("html").ParseGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("InitTemplates: %w", err)
+	}
+	return tmpls, nil
+}
+
+// DefaultTemplates parses the templates embedded in the package, which allows
+// the handlers to be used without an html directory on disk.
+func DefaultTemplates() (*template.Template, error) {
+	tmpls, err := template.New("html").ParseFS(templateFS, "html/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("DefaultTemplates: %w", err)
+	}
+	return tmpls, nil
+}
+
+const MsgTemplateError = "Sorry, the server was unable to display this page. Please contact the administrator."
+
+// RenderTemplate executes the named template with the given data and writes the result to the provided HTTP response writer.
+// If an error occurs during template execution, the HTTP response status is set to Internal Server Error (HTTP 500), and the function returns the error.
+// The caller must ensure no further writes are done for a non-nil error.
+func RenderTemplate(t *template.Template, w http.ResponseWriter, name string, data interface{}) error {
+	// handle nil template
+	if t == nil {
+		return errors.New("RenderTemplate: nil template")
+	}
+
+	// Create a buffer to store the template output since if an error occurs executing the template or writing its output, execution stops, but partial results may already have been written to the output writer.
+	var tmplBuffer bytes.Buffer
+
+	// Execute the template with the provided data.
+	err := t.ExecuteTemplate(&tmplBuffer, name, data)
+	if err != nil {
+		// If an error occurs, set the HTTP response status to Internal Server Error (HTTP 500).
+		http.Error(w, MsgTemplateError, http.StatusInternalServerError)
+		return err
+	}
+
+	// Write the template output to the response writer and check for errors.
+	_, writeErr := tmplBuffer.WriteTo(w)
+	if writeErr != nil {
+		// If an error occurs while writing to the response writer, return it.
+		http.Error(w, MsgTemplateError, http.StatusInternalServerError)
+		return writeErr
+	}
+
+	return nil
+}
+
+// Formats of the diagnostic responses, which are selected by the format query
+// parameter or the Accept header. See NegotiateFormat.
+const (
+	FormatHTML = "html"
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// formatMediaTypes maps media types in the Accept header to formats.
+var formatMediaTypes = map[string]string{
+	"text/html":             FormatHTML,
+	"application/xhtml+xml": FormatHTML,
+	"application/json":      FormatJSON,
+	"text/plain":            FormatText,
+}
+
+// NegotiateFormat returns the format of the response to r. The format query
+// parameter, if present, is used, otherwise the format of the media type in
+// the Accept header with the highest quality, preferring the earliest, or def
+// if none is acceptable or the media type is a wildcard. An error is returned
+// if the format query parameter is not html, json, or text.
+func NegotiateFormat(r *http.Request, def string) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format = strings.ToLower(format); format {
+		case FormatHTML, FormatJSON, FormatText:
+			return format, nil
+		}
+		return "", fmt.Errorf("invalid format %q (valid: html, json, text)", format)
+	}
+
+	best, bestQuality := def, 0.0
+	for _, accept := range r.Header.Values("Accept") {
+		for _, value := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(value)
+			if err != nil {
+				continue
+			}
+
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					continue
+				}
+			}
+			if quality <= bestQuality {
+				continue
+			}
+
+			if format, ok := formatMediaTypes[mediaType]; ok {
+				best, bestQuality = format, quality
+			} else if mediaType == "*/*" || (mediaType == "text/*" && def != FormatJSON) {
+				best, bestQuality = def, quality
+			} else if mediaType == "text/*" {
+				best, bestQuality = FormatText, quality
+			}
+		}
+	}
+
+	return best, nil
+}
+
+// TextPage is the data of a diagnostic response, which is rendered as JSON,
+// plain text using Text, or HTML.
+type TextPage interface {
+	Text() string
+}
+
+// TextPageName is the name of the HTML template for a TextPage without its
+// own template, which shows the Title and Text of the page.
+const TextPageName = "text.html"
+
+// RenderPage writes data in the format negotiated for r, with def as the
+// default format. HTML uses the named template, or plain text if t does not
+// define it, e.g., an older html directory. If the format is invalid, the
+// HTTP response status is set to Bad Request (HTTP 400) and the error is
+// returned. The caller must ensure no further writes are done for a non-nil
+// error.
+func RenderPage(t *template.Template, w http.ResponseWriter, r *http.Request, name string, data TextPage, def string) error {
+	format, err := NegotiateFormat(r, def)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	switch {
+	case format == FormatJSON:
+		return RenderJSON(w, data)
+	case format == FormatHTML && t != nil && t.Lookup(name) != nil:
+		return RenderTemplate(t, w, name, data)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = fmt.Fprint(w, data.Text())
+	return err
+}
+
+// RenderJSON writes data as indented JSON. If an error occurs encoding data,
+// the HTTP response status is set to Internal Server Error (HTTP 500) and the
+// error is returned.
+func RenderJSON(w http.ResponseWriter, data any) error {
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		http.Error(w, MsgTemplateError, http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		accept      string
+		def         string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "No Accept", target: "/", def: FormatText, expected: FormatText},
+		{name: "JSON", target: "/", accept: "application/json", def: FormatText, expected: FormatJSON},
+		{name: "HTML", target: "/", accept: "text/html", def: FormatText, expected: FormatHTML},
+		{name: "Text", target: "/", accept: "text/plain", def: FormatHTML, expected: FormatText},
+		{name: "Browser", target: "/", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", def: FormatText, expected: FormatHTML},
+		{name: "Quality", target: "/", accept: "text/html;q=0.5, application/json", def: FormatText, expected: FormatJSON},
+		{name: "Earliest", target: "/", accept: "text/plain, application/json", def: FormatHTML, expected: FormatText},
+		{name: "Wildcard", target: "/", accept: "*/*", def: FormatHTML, expected: FormatHTML},
+		{name: "Text wildcard", target: "/", accept: "text/*", def: FormatJSON, expected: FormatText},
+		{name: "Unknown", target: "/", accept: "image/png", def: FormatText, expected: FormatText},
+		{name: "Format", target: "/?format=json", accept: "text/html", def: FormatText, expected: FormatJSON},
+		{name: "Format case", target: "/?format=HTML", def: FormatText, expected: FormatHTML},
+		{name: "Invalid format", target: "/?format=xml", def: FormatText, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			got, err := NegotiateFormat(req, tt.def)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRenderPage(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		accept       string
+		expectedCode int
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "Headers HTML",
+			target:       "/headers",
+			expectedCode: http.StatusOK,
+			expectedType: "text/html; charset=utf-8",
+			expectedBody: "<td>X-Test</td>",
+		},
+		{
+			name:         "Headers JSON",
+			target:       "/headers",
+			accept:       "application/json",
+			expectedCode: http.StatusOK,
+			expectedType: "application/json",
+			expectedBody: `"key": "X-Test"`,
+		},
+		{
+			name:         "Headers text",
+			target:       "/headers?format=text",
+			expectedCode: http.StatusOK,
+			expectedType: "text/plain; charset=utf-8",
+			expectedBody: "X-Test: value\n",
+		},
+		{
+			name:         "Remote JSON",
+			target:       "/remote?format=json",
+			expectedCode: http.StatusOK,
+			expectedType: "application/json",
+			expectedBody: `"remoteAddr": "192.0.2.1:1234"`,
+		},
+		{
+			name:         "Remote HTML",
+			target:       "/remote",
+			accept:       "text/html",
+			expectedCode: http.StatusOK,
+			expectedType: "text/html; charset=utf-8",
+			expectedBody: "RemoteAddr: 192.0.2.1:1234",
+		},
+		{
+			name:         "Request JSON",
+			target:       "/request",
+			accept:       "application/json",
+			expectedCode: http.StatusOK,
+			expectedType: "application/json",
+			expectedBody: `"url": "/request"`,
+		},
+		{
+			name:         "Build JSON",
+			target:       "/build?format=json",
+			expectedCode: http.StatusOK,
+			expectedType: "application/json",
+			expectedBody: `"pid": `,
+		},
+		{
+			name:         "TLS JSON",
+			target:       "/tls?format=json",
+			expectedCode: http.StatusOK,
+			expectedType: "application/json",
+			expectedBody: `"tls": false`,
+		},
+		{
+			name:         "Invalid format",
+			target:       "/remote?format=xml",
+			expectedCode: http.StatusBadRequest,
+			expectedType: "text/plain; charset=utf-8",
+			expectedBody: "invalid format",
+		},
+	}
+
+	mux := handler.NewServeMux()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("X-Test", "value")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("expected status code %d, got %d", tt.expectedCode, rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.expectedType {
+				t.Errorf("expected Content-Type %q, got %q", tt.expectedType, got)
+			}
+			if !strings.Contains(rr.Body.String(), tt.expectedBody) {
+				t.Errorf("expected body to contain %q, got %q", tt.expectedBody, rr.Body.String())
+			}
+			if tt.expectedType == "application/json" && !json.Valid(rr.Body.Bytes()) {
+				t.Errorf("expected valid JSON, got %q", rr.Body.String())
+			}
+		})
+	}
+}
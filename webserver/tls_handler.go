@@ -0,0 +1,168 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TLSPageData holds the data for TLSHandler.
+type TLSPageData struct {
+	Title              string            `json:"-"`                  // Title of the page.
+	TLS                bool              `json:"tls"`                // TLS is true if the connection uses TLS.
+	Protocol           string            `json:"protocol"`           // Protocol of the connection, e.g., h2.
+	Version            string            `json:"version"`            // Version of TLS, e.g., TLS 1.3.
+	CipherSuite        string            `json:"cipherSuite"`        // CipherSuite negotiated.
+	NegotiatedProtocol string            `json:"negotiatedProtocol"` // NegotiatedProtocol by ALPN.
+	ServerName         string            `json:"serverName"`         // ServerName requested by the client (SNI).
+	DidResume          bool              `json:"didResume"`          // DidResume is true if the session was resumed.
+	VerifiedChains     int               `json:"verifiedChains"`     // VerifiedChains is the number of verified chains.
+	Verified           bool              `json:"verified"`           // Verified is true if a client certificate chain was verified.
+	PeerCertificates   []CertificateInfo `json:"peerCertificates"`   // PeerCertificates sent by the client.
+}
+
+// CertificateInfo holds the subject, issuer, names, serial number, and
+// validity of a certificate.
+type CertificateInfo struct {
+	Subject        string    `json:"subject"`
+	Issuer         string    `json:"issuer"`
+	DNSNames       []string  `json:"dnsNames"`
+	EmailAddresses []string  `json:"emailAddresses"`
+	IPAddresses    []string  `json:"ipAddresses"`
+	URIs           []string  `json:"uris"`
+	SerialNumber   string    `json:"serialNumber"`
+	NotBefore      time.Time `json:"notBefore"`
+	NotAfter       time.Time `json:"notAfter"`
+}
+
+// NewCertificateInfo returns the CertificateInfo of cert.
+func NewCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	return CertificateInfo{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		DNSNames:       append([]string{}, cert.DNSNames...),
+		EmailAddresses: append([]string{}, cert.EmailAddresses...),
+		IPAddresses:    stringers(cert.IPAddresses),
+		URIs:           stringers(cert.URIs),
+		SerialNumber:   cert.SerialNumber.String(),
+		NotBefore:      cert.NotBefore.UTC(),
+		NotAfter:       cert.NotAfter.UTC(),
+	}
+}
+
+// Text returns the details of the TLS connection, each on a line, followed
+// by each client certificate.
+func (d TLSPageData) Text() string {
+	var b strings.Builder
+
+	if !d.TLS {
+		fmt.Fprintln(&b, "TLS: false")
+		return b.String()
+	}
+
+	fmt.Fprintln(&b, "TLS: true")
+	fmt.Fprintf(&b, "Protocol: %v\n", d.Protocol)
+	fmt.Fprintf(&b, "Version: %v\n", d.Version)
+	fmt.Fprintf(&b, "CipherSuite: %v\n", d.CipherSuite)
+	fmt.Fprintf(&b, "NegotiatedProtocol: %v\n", d.NegotiatedProtocol)
+	fmt.Fprintf(&b, "ServerName: %v\n", d.ServerName)
+	fmt.Fprintf(&b, "DidResume: %v\n", d.DidResume)
+	fmt.Fprintf(&b, "PeerCertificates: %d\n", len(d.PeerCertificates))
+	fmt.Fprintf(&b, "VerifiedChains: %d\n", d.VerifiedChains)
+	fmt.Fprintf(&b, "Verified: %v\n", d.Verified)
+
+	for i, cert := range d.PeerCertificates {
+		fmt.Fprintf(&b, "\nPeerCertificate: %d\n", i)
+		cert.write(&b)
+	}
+
+	return b.String()
+}
+
+// TLSHandler responds with the details of the TLS connection, including the
+// protocol, e.g., h2 or h3, the negotiated version, cipher suite, and ALPN
+// protocol, and the client certificates and whether their chain was verified.
+// The response is plain text by default. See RenderPage.
+func (h *Handler) TLSHandler(w http.ResponseWriter, r *http.Request) {
+	logger := Logger(r.Context())
+	logger.Debug("TLS Handler")
+
+	if !ValidMethod(w, r, http.MethodGet) {
+		logger.Error("invalid method")
+		return
+	}
+
+	// try and force client not to cache content
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	data := TLSPageData{
+		Title:            "TLS",
+		Protocol:         Protocol(r),
+		PeerCertificates: []CertificateInfo{},
+	}
+
+	if state := r.TLS; state != nil {
+		data.TLS = true
+		data.Version = tls.VersionName(state.Version)
+		data.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+		data.NegotiatedProtocol = state.NegotiatedProtocol
+		data.ServerName = state.ServerName
+		data.DidResume = state.DidResume
+		data.VerifiedChains = len(state.VerifiedChains)
+		data.Verified = len(state.VerifiedChains) > 0
+		for _, cert := range state.PeerCertificates {
+			data.PeerCertificates = append(data.PeerCertificates, NewCertificateInfo(cert))
+		}
+	}
+
+	err := RenderPage(h.Templates(), w, r, TextPageName, data, FormatText)
+	if err != nil {
+		logger.Error("failed to RenderPage", "err", err)
+		return
+	}
+}
+
+// write writes each field of c on a line to w.
+func (c CertificateInfo) write(w io.Writer) {
+	fmt.Fprintf(w, "Subject: %v\n", c.Subject)
+	fmt.Fprintf(w, "Issuer: %v\n", c.Issuer)
+	fmt.Fprintf(w, "DNSNames: %v\n", strings.Join(c.DNSNames, ", "))
+	fmt.Fprintf(w, "EmailAddresses: %v\n", strings.Join(c.EmailAddresses, ", "))
+	fmt.Fprintf(w, "IPAddresses: %v\n", strings.Join(c.IPAddresses, ", "))
+	fmt.Fprintf(w, "URIs: %v\n", strings.Join(c.URIs, ", "))
+	fmt.Fprintf(w, "SerialNumber: %v\n", c.SerialNumber)
+	fmt.Fprintf(w, "NotBefore: %v\n", c.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(w, "NotAfter: %v\n", c.NotAfter.Format(time.RFC3339))
+}
+
+// writeX509Certificate writes the subject, issuer, names, serial number, and
+// validity of cert to w.
+func writeX509Certificate(w io.Writer, cert *x509.Certificate) {
+	NewCertificateInfo(cert).write(w)
+}
+
+// stringers returns the strings of values.
+func stringers[T fmt.Stringer](values []T) []string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, v.String())
+	}
+	return s
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTLSHandler(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeTestCertificate(t, dir, "server")
+	clientCert, clientKey := writeTestCertificate(t, dir, "client")
+	otherCert, otherKey := writeTestCertificate(t, dir, "other")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultServerConfig()
+	config.CertFile = serverCert
+	config.KeyFile = serverKey
+	config.ClientCAFile = clientCert
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	srv := NewServer(config, http.HandlerFunc(handler.TLSHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Serve(ctx, ln)
+
+	rootCAs, err := LoadCertPool(serverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		certFile    string
+		keyFile     string
+		expectedErr bool
+		expected    []string
+	}{
+		{
+			name: "No client certificate",
+			expected: []string{
+				"TLS: true\n",
+				"ServerName: server\n",
+				"PeerCertificates: 0\n",
+			},
+		},
+		{
+			name:     "Verified client certificate",
+			certFile: clientCert,
+			keyFile:  clientKey,
+			expected: []string{
+				"PeerCertificates: 1\n",
+				"VerifiedChains: 1\n",
+				"Verified: true\n",
+				"PeerCertificate: 0\nSubject: CN=client\n",
+				"DNSNames: client\n",
+			},
+		},
+		{
+			name:        "Unknown client certificate",
+			certFile:    otherCert,
+			keyFile:     otherKey,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig := &tls.Config{RootCAs: rootCAs, ServerName: "server"}
+			if tt.certFile != "" {
+				cert, err := tls.LoadX509KeyPair(tt.certFile, tt.keyFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// send the certificate even if not issued by an acceptable CA
+				tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+					return &cert, nil
+				}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+			resp, err := client.Get("https://" + ln.Addr().String() + "/tls")
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			for _, want := range tt.expected {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("expected %q in body:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestTLSHandlerWithoutTLS(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handler.TLSHandler(rr, httptest.NewRequest(http.MethodGet, "/tls", nil))
+
+	if rr.Body.String() != "TLS: false\n" {
+		t.Errorf("expected 'TLS: false', got %q", rr.Body.String())
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		expected    tls.ClientAuthType
+		expectedErr bool
+	}{
+		{name: "", expected: tls.NoClientCert},
+		{name: "none", expected: tls.NoClientCert},
+		{name: "request", expected: tls.RequestClientCert},
+		{name: "Require", expected: tls.RequireAnyClientCert},
+		{name: "verify", expected: tls.RequireAndVerifyClientCert},
+		{name: "invalid", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ClientAuth(tt.name)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestClientCAReload(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeTestCertificate(t, dir, "server")
+	clientCert, clientKey := writeTestCertificate(t, dir, "client")
+	otherCert, otherKey := writeTestCertificate(t, dir, "other")
+
+	clientCAFile := filepath.Join(dir, "clientca.crt")
+	copyFile(t, clientCert, clientCAFile)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultServerConfig()
+	config.CertFile = serverCert
+	config.KeyFile = serverKey
+	config.ClientCAFile = clientCAFile
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	srv := NewServer(config, http.HandlerFunc(handler.TLSHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Serve(ctx, ln)
+
+	rootCAs, err := LoadCertPool(serverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get requests /tls on a new connection with the certificate
+	get := func(certFile, keyFile string) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tlsConfig := &tls.Config{
+			RootCAs:    rootCAs,
+			ServerName: "server",
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return &cert, nil
+			},
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}}
+
+		resp, err := client.Get("https://" + ln.Addr().String() + "/tls")
+		if err != nil {
+			return err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if !strings.Contains(string(body), "Verified: true\n") {
+			t.Errorf("expected verified client certificate:\n%s", body)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		caFile   string
+		certFile string
+		keyFile  string
+		expected bool
+	}{
+		{name: "Client", certFile: clientCert, keyFile: clientKey, expected: true},
+		{name: "Other", certFile: otherCert, keyFile: otherKey, expected: false},
+		{name: "Other after reload", caFile: otherCert, certFile: otherCert, keyFile: otherKey, expected: true},
+		{name: "Client after reload", certFile: clientCert, keyFile: clientKey, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.caFile != "" {
+				copyFile(t, tt.caFile, clientCAFile)
+				if err := srv.ReloadCertificate(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := get(tt.certFile, tt.keyFile); (err == nil) != tt.expected {
+				t.Errorf("expected success %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+// copyFile copies the file src to dst.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
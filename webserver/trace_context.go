@@ -0,0 +1,187 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// maxTraceStateLength limits the length of the tracestate header that is kept.
+const maxTraceStateLength = 512
+
+// TraceContext holds the W3C trace context of a request.
+// See https://www.w3.org/TR/trace-context/.
+type TraceContext struct {
+	Version  string // Version is the traceparent version, e.g., "00".
+	TraceID  string // TraceID identifies the whole trace.
+	ParentID string // ParentID is the span ID of the caller.
+	SpanID   string // SpanID is the span ID generated for this request.
+	Flags    string // Flags are the trace flags, e.g., "01" if sampled.
+	State    string // State is the tracestate header, if any.
+}
+
+// ErrInvalidTraceParent indicates a missing or invalid traceparent header.
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// ParseTraceContext parses the traceparent and tracestate headers and
+// generates a new SpanID for the request.
+func ParseTraceContext(header http.Header) (TraceContext, error) {
+	values := header.Values("Traceparent")
+	if len(values) != 1 {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+	tp := strings.TrimSpace(values[0])
+
+	// version "-" trace-id "-" parent-id "-" trace-flags, where future
+	// versions may append additional fields after a "-"
+	const length = 2 + 1 + 32 + 1 + 16 + 1 + 2
+	if len(tp) < length || (len(tp) > length && tp[length] != '-') {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+
+	tc := TraceContext{
+		Version:  tp[0:2],
+		TraceID:  tp[3:35],
+		ParentID: tp[36:52],
+		Flags:    tp[53:55],
+	}
+
+	if tp[2] != '-' || tp[35] != '-' || tp[52] != '-' ||
+		!lowerHex(tc.Version) || tc.Version == "ff" ||
+		(tc.Version == "00" && len(tp) != length) ||
+		!lowerHex(tc.TraceID) || allZeros(tc.TraceID) ||
+		!lowerHex(tc.ParentID) || allZeros(tc.ParentID) ||
+		!lowerHex(tc.Flags) {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+
+	state := strings.Join(header.Values("Tracestate"), ",")
+	if len(state) <= maxTraceStateLength {
+		tc.State = state
+	}
+
+	spanID, err := newSpanID()
+	if err != nil {
+		return TraceContext{}, err
+	}
+	tc.SpanID = spanID
+
+	return tc, nil
+}
+
+// TraceParent returns the traceparent header value to propagate the trace
+// to downstream requests, with SpanID as the parent.
+func (tc TraceContext) TraceParent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + tc.Flags
+}
+
+// SetTraceContext sets the traceparent and tracestate headers of header from
+// the trace context in ctx, if any, to propagate the trace to a downstream
+// request. It reports whether ctx has a trace context.
+func SetTraceContext(ctx context.Context, header http.Header) bool {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	header.Set("Traceparent", tc.TraceParent())
+	header.Del("Tracestate")
+	if tc.State != "" {
+		header.Set("Tracestate", tc.State)
+	}
+
+	return true
+}
+
+// TraceTransport is an http.RoundTripper that propagates the trace context
+// of the context of each request, e.g., the context of the incoming request
+// from AddRequestID, to the downstream request. See SetTraceContext.
+type TraceTransport struct {
+	Base http.RoundTripper // Base makes the requests, or http.DefaultTransport if nil.
+}
+
+// RoundTrip sets the trace context headers of a copy of req and uses Base to
+// make the request.
+func (t TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if _, ok := TraceContextFromContext(req.Context()); ok {
+		// a RoundTripper must not modify the request
+		req = req.Clone(req.Context())
+		SetTraceContext(req.Context(), req.Header)
+	}
+
+	return base.RoundTrip(req)
+}
+
+// newSpanID returns a random, non-zero span ID.
+func newSpanID() (string, error) {
+	b := make([]byte, 8)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		id := hex.EncodeToString(b)
+		if !allZeros(id) {
+			return id, nil
+		}
+	}
+}
+
+// lowerHex reports whether s only contains lowercase hexadecimal digits.
+func lowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// allZeros reports whether s only contains "0".
+func allZeros(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// TraceContextFromContext returns the trace context from ctx and whether it
+// was present.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+	tc, ok := ctx.Value(traceContextKey).(TraceContext)
+	return tc, ok
+}
+
+// TraceIDFromContext returns the trace ID from ctx if present, otherwise "".
+func TraceIDFromContext(ctx context.Context) string {
+	tc, _ := TraceContextFromContext(ctx)
+	return tc.TraceID
+}
+
+// SpanIDFromContext returns the span ID generated for the request from ctx
+// if present, otherwise "".
+func SpanIDFromContext(ctx context.Context) string {
+	tc, _ := TraceContextFromContext(ctx)
+	return tc.SpanID
+}
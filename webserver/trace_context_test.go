@@ -0,0 +1,210 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceContext(t *testing.T) {
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name          string
+		traceparent   []string
+		tracestate    []string
+		expectedError bool
+		expectedState string
+		expectedFlags string
+	}{
+		{
+			name:          "Valid",
+			traceparent:   []string{"00-" + traceID + "-" + parentID + "-01"},
+			expectedFlags: "01",
+		},
+		{
+			name:          "Valid with state",
+			traceparent:   []string{"00-" + traceID + "-" + parentID + "-00"},
+			tracestate:    []string{"rojo=00f067aa0ba902b7", "congo=t61rcWkgMzE"},
+			expectedState: "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE",
+			expectedFlags: "00",
+		},
+		{
+			name:          "Future version with extra field",
+			traceparent:   []string{"cc-" + traceID + "-" + parentID + "-01-what-the-future-will-be-like"},
+			expectedFlags: "01",
+		},
+		{name: "Missing", expectedError: true},
+		{
+			name:          "Multiple",
+			traceparent:   []string{"00-" + traceID + "-" + parentID + "-01", "00-" + traceID + "-" + parentID + "-01"},
+			expectedError: true,
+		},
+		{
+			name:          "Version 00 with extra field",
+			traceparent:   []string{"00-" + traceID + "-" + parentID + "-01-extra"},
+			expectedError: true,
+		},
+		{
+			name:          "Invalid version",
+			traceparent:   []string{"ff-" + traceID + "-" + parentID + "-01"},
+			expectedError: true,
+		},
+		{
+			name:          "Uppercase",
+			traceparent:   []string{"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + parentID + "-01"},
+			expectedError: true,
+		},
+		{
+			name:          "Zero trace ID",
+			traceparent:   []string{"00-00000000000000000000000000000000-" + parentID + "-01"},
+			expectedError: true,
+		},
+		{
+			name:          "Zero parent ID",
+			traceparent:   []string{"00-" + traceID + "-0000000000000000-01"},
+			expectedError: true,
+		},
+		{
+			name:          "Bad separator",
+			traceparent:   []string{"00_" + traceID + "-" + parentID + "-01"},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Traceparent": tt.traceparent, "Tracestate": tt.tracestate}
+
+			tc, err := ParseTraceContext(header)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if tt.expectedError {
+				return
+			}
+
+			if tc.TraceID != traceID || tc.ParentID != parentID || tc.Flags != tt.expectedFlags {
+				t.Errorf("unexpected trace context %+v", tc)
+			}
+			if tc.State != tt.expectedState {
+				t.Errorf("expected state %q, got %q", tt.expectedState, tc.State)
+			}
+			if len(tc.SpanID) != 16 || !lowerHex(tc.SpanID) || tc.SpanID == parentID {
+				t.Errorf("invalid span ID %q", tc.SpanID)
+			}
+			if want := "00-" + traceID + "-" + tc.SpanID + "-" + tt.expectedFlags; tc.TraceParent() != want {
+				t.Errorf("expected traceparent %q, got %q", want, tc.TraceParent())
+			}
+		})
+	}
+}
+
+func TestTraceContextFromRequest(t *testing.T) {
+	var traceID, spanID string
+	h := AddRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = TraceIDFromContext(r.Context())
+		spanID = SpanIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected trace ID %q", traceID)
+	}
+	if spanID == "" {
+		t.Error("expected span ID")
+	}
+}
+
+func TestTraceTransport(t *testing.T) {
+	var downstream http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	client := &http.Client{Transport: TraceTransport{}}
+
+	tests := []struct {
+		name          string
+		traceParent   string
+		traceState    string
+		expectedState string
+	}{
+		{
+			name:          "Trace context",
+			traceParent:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceState:    "vendor=value",
+			expectedState: "vendor=value",
+		},
+		{
+			name:        "Without tracestate",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		},
+		{
+			name: "Without trace context",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expected string
+			h := AddRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc, ok := TraceContextFromContext(r.Context()); ok {
+					expected = tc.TraceParent()
+				}
+
+				req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, backend.URL, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+
+				// the outgoing request is not modified
+				if req.Header.Get("Traceparent") != "" {
+					t.Error("expected the request to not be modified")
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.traceParent != "" {
+				req.Header.Set("Traceparent", tt.traceParent)
+			}
+			if tt.traceState != "" {
+				req.Header.Set("Tracestate", tt.traceState)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			// the span ID of the request is the parent of the downstream request
+			if got := downstream.Get("Traceparent"); got != expected {
+				t.Errorf("expected traceparent %q, got %q", expected, got)
+			}
+			if got := downstream.Get("Tracestate"); got != tt.expectedState {
+				t.Errorf("expected tracestate %q, got %q", tt.expectedState, got)
+			}
+		})
+	}
+}
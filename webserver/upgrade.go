@@ -0,0 +1,254 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpgradeTimeout limits the time for the new process of an Upgrade to become
+// ready.
+const UpgradeTimeout = 30 * time.Second
+
+// Environment variables passed to the new process of an Upgrade. The
+// readiness pipe is file descriptor 3, followed by the listeners.
+const (
+	upgradeFDsEnv     = "UPGRADE_LISTEN_FDS"
+	upgradeFDNamesEnv = "UPGRADE_LISTEN_FDNAMES"
+	upgradeUnlinkEnv  = "UPGRADE_LISTEN_UNLINK"
+)
+
+// Upgrade starts a new process of the executable with the same arguments,
+// passing the listeners being served, including for HTTP/3, and waits up to UpgradeTimeout for it
+// to be ready. If it is ready, its process ID is returned and the caller
+// should shut down this server, which drains the requests in progress.
+// Otherwise, the new process is killed and this server keeps serving.
+func (s *Server) Upgrade() (int, error) {
+	if !s.upgradeMu.TryLock() {
+		return 0, errors.New("upgrade in progress")
+	}
+	defer s.upgradeMu.Unlock()
+
+	s.mu.Lock()
+	listeners, packetConn := s.listeners, s.packetConn
+	s.mu.Unlock()
+	if len(listeners) == 0 {
+		return 0, errors.New("not serving")
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	files := []*os.File{w}
+	names := make([]string, 0, len(listeners))
+	unlink := make([]string, 0, len(listeners))
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	for _, l := range listeners {
+		f, err := listenerFile(l.Listener)
+		if err != nil {
+			closeFiles()
+			return 0, fmt.Errorf("failed to pass %s: %w", l.Addr(), err)
+		}
+		files = append(files, f)
+		names = append(names, l.name())
+		unlink = append(unlink, strconv.FormatBool(l.unlink))
+	}
+	if packetConn != nil {
+		f, err := socketFile(packetConn)
+		if err != nil {
+			closeFiles()
+			return 0, fmt.Errorf("failed to pass %s: %w", packetConn.LocalAddr(), err)
+		}
+		files = append(files, f)
+		names = append(names, ProtocolHTTP3)
+		unlink = append(unlink, "false")
+	}
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(upgradeEnv(os.Environ()),
+		upgradeFDsEnv+"="+strconv.Itoa(len(files)-1),
+		upgradeFDNamesEnv+"="+strings.Join(names, ":"),
+		upgradeUnlinkEnv+"="+strings.Join(unlink, ":"),
+	)
+	cmd.ExtraFiles = files
+
+	err = cmd.Start()
+	// the new process has its own copies, so EOF is read if it exits
+	closeFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	if err := waitReady(r, UpgradeTimeout); err != nil {
+		cmd.Process.Kill()
+		<-exited
+		return 0, err
+	}
+
+	s.upgraded.Store(true)
+
+	// the new process removes Unix domain sockets when it stops
+	for _, l := range listeners {
+		if ul, ok := unwrapListener(l.Listener).(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	return cmd.Process.Pid, nil
+}
+
+// UpgradeListeners returns the listeners passed by the parent process during
+// an Upgrade, and ready to call when they are serving, or nil if the process
+// was not started by an Upgrade. The environment variables are unset so child
+// processes do not inherit them.
+func UpgradeListeners() (listeners []InheritedListener, ready func() error, err error) {
+	defer func() {
+		os.Unsetenv(upgradeFDsEnv)
+		os.Unsetenv(upgradeFDNamesEnv)
+		os.Unsetenv(upgradeUnlinkEnv)
+	}()
+
+	n, err := strconv.Atoi(os.Getenv(upgradeFDsEnv))
+	if err != nil || n <= 0 {
+		return nil, nil, nil
+	}
+
+	pipe := os.NewFile(listenFDsStart, "ready")
+	listeners, err = inheritListeners(listenFDsStart+1, n, strings.Split(os.Getenv(upgradeFDNamesEnv), ":"))
+	if err != nil {
+		pipe.Close()
+		return nil, nil, err
+	}
+
+	// remove the Unix domain sockets created by the previous process, but not
+	// those of systemd, when this process stops
+	for i, unlink := range strings.Split(os.Getenv(upgradeUnlinkEnv), ":") {
+		if i >= len(listeners) || unlink != "true" {
+			continue
+		}
+		if ul, ok := listeners[i].Listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(true)
+			listeners[i].unlink = true
+		}
+	}
+
+	ready = func() error {
+		defer pipe.Close()
+		_, err := pipe.Write([]byte{1})
+		return err
+	}
+
+	return listeners, ready, nil
+}
+
+// waitReady waits up to timeout for the new process to write to r.
+func waitReady(r *os.File, timeout time.Duration) error {
+	if err := r.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	_, err := r.Read(make([]byte, 1))
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("new process exited before it was ready")
+	case err != nil:
+		return fmt.Errorf("new process not ready: %w", err)
+	}
+
+	return nil
+}
+
+// upgradeEnv returns env without the variables that only apply to this
+// process. The new process sends the systemd watchdog notifications once it
+// is the main process.
+func upgradeEnv(env []string) []string {
+	omit := []string{
+		"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", "WATCHDOG_PID",
+		upgradeFDsEnv, upgradeFDNamesEnv, upgradeUnlinkEnv,
+	}
+
+	result := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(omit, name) {
+			result = append(result, kv)
+		}
+	}
+
+	return result
+}
+
+// name returns the name of the listener passed to a new process, which
+// configures it if its address does not match.
+func (l Listener) name() string {
+	switch {
+	case l.Redirect:
+		return "redirect"
+	case l.TLS:
+		return "https"
+	}
+	return ""
+}
+
+// unwrapListener returns the listener inherited as ln, if any, or ln.
+func unwrapListener(ln net.Listener) net.Listener {
+	for {
+		inherited, ok := ln.(InheritedListener)
+		if !ok {
+			return ln
+		}
+		ln = inherited.Listener
+	}
+}
+
+// listenerFile returns a duplicate file descriptor for ln.
+func listenerFile(ln net.Listener) (*os.File, error) {
+	return socketFile(unwrapListener(ln))
+}
+
+// socketFile returns a duplicate file descriptor for the listener or packet
+// connection socket.
+func socketFile(socket any) (*os.File, error) {
+	filer, ok := socket.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("unsupported socket %T", socket)
+	}
+	return filer.File()
+}
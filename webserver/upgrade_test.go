@@ -0,0 +1,153 @@
+/*
+Copyright 2023 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+this file except in compliance with the License.  You may obtain a copy of the
+License at http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed
+under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+CONDITIONS OF ANY KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestUpgradeEnv(t *testing.T) {
+	env := []string{
+		"PATH=/usr/bin",
+		"LISTEN_PID=1",
+		"LISTEN_FDS=1",
+		"LISTEN_FDNAMES=https",
+		"NOTIFY_SOCKET=/run/systemd/notify",
+		"WATCHDOG_USEC=30000000",
+		"WATCHDOG_PID=1",
+		"UPGRADE_LISTEN_FDS=2",
+		"UPGRADE_LISTEN_FDNAMES=https:redirect",
+		"UPGRADE_LISTEN_UNLINK=false:false",
+		"GOWEBSERVER_ADDR=:8443",
+	}
+	expected := []string{
+		"PATH=/usr/bin",
+		"NOTIFY_SOCKET=/run/systemd/notify",
+		"WATCHDOG_USEC=30000000",
+		"GOWEBSERVER_ADDR=:8443",
+	}
+
+	if got := upgradeEnv(env); !slices.Equal(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(w *os.File)
+		wantErr string
+	}{
+		{name: "Ready", write: func(w *os.File) { w.Write([]byte{1}) }},
+		{name: "Exited", write: func(w *os.File) {}, wantErr: "exited"},
+		{name: "Timeout", wantErr: "not ready"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			defer w.Close()
+
+			if tt.write != nil {
+				tt.write(w)
+				w.Close()
+			}
+
+			err = waitReady(r, 50*time.Millisecond)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestListenerFile(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	tests := []struct {
+		name    string
+		ln      net.Listener
+		wantErr bool
+	}{
+		{name: "TCP", ln: ln},
+		{name: "Inherited", ln: InheritedListener{Listener: ln, Name: "https"}},
+		{name: "Unsupported", ln: &ProxyProtocolListener{Listener: ln}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := listenerFile(tt.ln)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			// inheritListeners closes the file descriptor it is passed
+			fd, err := syscall.Dup(int(f.Fd()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			inherited, err := inheritListeners(fd, 1, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer inherited[0].Close()
+
+			if inherited[0].Addr().String() != ln.Addr().String() {
+				t.Errorf("expected addr %s, got %s", ln.Addr(), inherited[0].Addr())
+			}
+		})
+	}
+}
+
+func TestBuildHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handler.BuildHandler(rr, httptest.NewRequest(http.MethodGet, "/build", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	expected := buildDateTime.Format("2006-01-02 15:04:05") + "\nPID: " + strconv.Itoa(os.Getpid()) + "\n"
+	if rr.Body.String() != expected {
+		t.Errorf("expected body %q, got %q", expected, rr.Body.String())
+	}
+}
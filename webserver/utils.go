@@ -1,4 +1,4 @@
-package main
+package webserver
 
 import (
 	"net/http"
@@ -56,12 +56,13 @@ func ExecutableDateTime() (time.Time, error) {
 	return fileInfo.ModTime(), nil
 }
 
-// RealIP returns the real IP address from the request headers or RemoteAddr.
+// RealIP returns the client IP address resolved by ProxyConfig.AddClientInfo,
+// if present in the request context, otherwise the host of RemoteAddr.
+// Request headers are only used if they are from a trusted proxy.
 func RealIP(r *http.Request) string {
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP == "" {
-		realIP = r.RemoteAddr
+	if info, ok := ClientInfoFromContext(r.Context()); ok {
+		return info.IP
 	}
 
-	return realIP
+	return hostOnly(r.RemoteAddr)
 }
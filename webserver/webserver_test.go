@@ -11,7 +11,7 @@ CONDITIONS OF ANY KIND, either express or implied.  See the License for the
 specific language governing permissions and limitations under the License.
 */
 
-package main
+package webserver
 
 import (
 	"bytes"
@@ -220,7 +220,17 @@ func TestRequestHandler(t *testing.T) {
 				return req
 			}(),
 			expectedStatus: http.StatusOK,
-			expectedBody:   "GET /request HTTP/1.1",
+			expectedBody:   "Protocol: http/1.1\n\nGET /request HTTP/1.1",
+		},
+		{
+			name: "HTTP/2 without TLS",
+			request: func() *http.Request {
+				req, _ := http.NewRequest(http.MethodGet, "/request", nil)
+				req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+				return req
+			}(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Protocol: h2c\n\nGET /request HTTP/2.0",
 		},
 	}
 